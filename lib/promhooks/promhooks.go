@@ -0,0 +1,112 @@
+// Package promhooks exposes Prometheus metrics for the rightscale and sumologic packages through their hooks
+package promhooks
+
+import (
+	"strconv"
+
+	"github.com/angelamancini/SJP_Go_Packages/lib/rightscale"
+	"github.com/angelamancini/SJP_Go_Packages/lib/sumologic"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors fed by the hooks
+// Use RightScale and Sumologic to get hooks for a rightscale.Client or a sumologic uploader
+type Metrics struct {
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	sends           *prometheus.CounterVec
+	sendDuration    prometheus.Histogram
+	sentBytes       prometheus.Counter
+}
+
+// rightscaleHooks adapts Metrics to rightscale.Hooks
+type rightscaleHooks struct {
+	m *Metrics
+}
+
+// sumologicHooks adapts Metrics to sumologic.Hooks
+type sumologicHooks struct {
+	m *Metrics
+}
+
+// New creates the collectors and registers them with reg, namespace prefixes every metric name
+// e.g. a namespace of myjob gives myjob_rightscale_requests_total
+func New(reg prometheus.Registerer, namespace string) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rightscale",
+			Name:      "requests_total",
+			Help:      "Rightscale API requests by method, endpoint template and status code.",
+		}, []string{"method", "endpoint", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "rightscale",
+			Name:      "request_duration_seconds",
+			Help:      "Rightscale API request latency by method and endpoint template.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "endpoint"}),
+		sends: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sumologic",
+			Name:      "sends_total",
+			Help:      "Sumologic uploads by status code.",
+		}, []string{"code"}),
+		sendDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "sumologic",
+			Name:      "send_duration_seconds",
+			Help:      "Sumologic upload latency.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		sentBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sumologic",
+			Name:      "sent_bytes_total",
+			Help:      "Bytes handed to Sumologic uploads before compression.",
+		}),
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.requestDuration, m.sends, m.sendDuration, m.sentBytes} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// RightScale returns hooks for rightscale.Client.WithHooks or rightscale.NewWithHooks
+func (m *Metrics) RightScale() rightscale.Hooks {
+	return rightscaleHooks{m: m}
+}
+
+// Sumologic returns hooks for sumologic.WithHooks
+func (m *Metrics) Sumologic() sumologic.Hooks {
+	return sumologicHooks{m: m}
+}
+
+// BeforeRequest implements rightscale.Hooks, requests are only counted once they finish
+func (h rightscaleHooks) BeforeRequest(req rightscale.RequestInfo) {}
+
+// AfterRequest implements rightscale.Hooks
+func (h rightscaleHooks) AfterRequest(req rightscale.RequestInfo, resp rightscale.ResponseInfo) {
+	h.m.requests.WithLabelValues(req.Method, req.Endpoint, statusLabel(resp.StatusCode)).Inc()
+	h.m.requestDuration.WithLabelValues(req.Method, req.Endpoint).Observe(resp.Duration.Seconds())
+}
+
+// BeforeSend implements sumologic.Hooks, uploads are only counted once they finish
+func (h sumologicHooks) BeforeSend(info sumologic.SendInfo) {}
+
+// AfterSend implements sumologic.Hooks
+func (h sumologicHooks) AfterSend(info sumologic.SendInfo, result sumologic.SendResult) {
+	h.m.sends.WithLabelValues(statusLabel(result.StatusCode)).Inc()
+	h.m.sendDuration.Observe(result.Duration.Seconds())
+	h.m.sentBytes.Add(float64(info.Bytes))
+}
+
+// statusLabel turns a status code into a label value, network errors have no status code and are labelled error
+func statusLabel(code int) string {
+	if code == 0 {
+		return "error"
+	}
+	return strconv.Itoa(code)
+}
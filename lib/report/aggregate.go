@@ -0,0 +1,318 @@
+package report
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Footer is implemented by tables with a totals row, the ASCII and HTML renderers draw it below the data
+type Footer interface {
+	TableFooter() []string
+}
+
+// Aggregate summarises one column of a group of rows
+// Func is one of count, sum, min, max or avg, count ignores Column
+// Header names the result column, it defaults to e.g. sum(InstancesCount)
+type Aggregate struct {
+	Func   string
+	Column string
+	Header string
+}
+
+// aggregateExpr matches count, count(), sum(Column) and so on
+var aggregateExpr = regexp.MustCompile(`^\s*(\w+)\s*(?:\(\s*([^)]*?)\s*\))?\s*$`)
+
+// ParseAggregate parses an expression such as count or sum(InstancesCount)
+func ParseAggregate(s string) (Aggregate, error) {
+	m := aggregateExpr.FindStringSubmatch(s)
+	if m == nil {
+		return Aggregate{}, errors.Errorf("invalid aggregate %q, expected e.g. count or sum(InstancesCount)", s)
+	}
+	a := Aggregate{Func: strings.ToLower(m[1]), Column: m[2]}
+	if _, err := a.reducer(); err != nil {
+		return Aggregate{}, err
+	}
+	if a.Func != "count" && a.Column == "" {
+		return Aggregate{}, errors.Errorf("aggregate %s needs a column e.g. %s(InstancesCount)", a.Func, a.Func)
+	}
+	return a, nil
+}
+
+// header returns the column header for the aggregate's results
+func (a Aggregate) header() string {
+	fn := strings.ToLower(a.Func)
+	switch {
+	case a.Header != "":
+		return a.Header
+	case fn == "count":
+		return "count"
+	}
+	return fn + "(" + a.Column + ")"
+}
+
+// reducer returns the function which turns a group's cells into the aggregate value
+func (a Aggregate) reducer() (func(values []string) string, error) {
+	switch strings.ToLower(a.Func) {
+	case "count":
+		return func(values []string) string { return strconv.Itoa(len(values)) }, nil
+	case "sum":
+		return func(values []string) string {
+			sum, _ := sumValues(values)
+			return formatNumber(sum)
+		}, nil
+	case "avg":
+		return func(values []string) string {
+			sum, n := sumValues(values)
+			if n == 0 {
+				return ""
+			}
+			return formatNumber(sum / float64(n))
+		}, nil
+	case "min":
+		return func(values []string) string { return extremeValue(values, -1) }, nil
+	case "max":
+		return func(values []string) string { return extremeValue(values, 1) }, nil
+	}
+	return nil, errors.Errorf("unknown aggregate %q, expected one of count, sum, min, max or avg", a.Func)
+}
+
+// sumValues adds up the cells which are numbers, returning the sum and how many numbers there were
+func sumValues(values []string) (float64, int) {
+	var sum float64
+	n := 0
+	for _, v := range values {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			continue
+		}
+		sum += f
+		n++
+	}
+	return sum, n
+}
+
+// extremeValue returns the smallest (direction -1) or largest (direction 1) non empty cell
+func extremeValue(values []string, direction int) string {
+	best := ""
+	for _, v := range values {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		if best == "" || compareValues(v, best)*direction > 0 {
+			best = v
+		}
+	}
+	return best
+}
+
+// formatNumber writes whole numbers without a decimal point and rounds everything else to two places
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// GroupBy groups the rows of t by the key columns and adds a column per aggregate
+// Groups are sorted by their keys and the footer holds the aggregates over every row
+func GroupBy(t Table, keys []string, aggs ...Aggregate) (Table, error) {
+	if len(aggs) == 0 {
+		aggs = []Aggregate{{Func: "count"}}
+	}
+	headers := t.TableHeaders()
+	keyIndexes, err := columnIndexes(headers, keys)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not group")
+	}
+	aggIndexes := make([]int, len(aggs))
+	reducers := make([]func([]string) string, len(aggs))
+	for n, a := range aggs {
+		if reducers[n], err = a.reducer(); err != nil {
+			return nil, err
+		}
+		aggIndexes[n] = -1
+		if a.Column != "" {
+			if aggIndexes[n], err = columnIndex(headers, a.Column); err != nil {
+				return nil, errors.WithMessage(err, "could not aggregate")
+			}
+		}
+	}
+
+	type group struct {
+		key    []string
+		values [][]string
+	}
+	groups := map[string]*group{}
+	var order []*group
+	all := make([][]string, len(aggs))
+	for _, row := range t.TableData() {
+		row = padRow(row, len(headers))
+		var key []string
+		for _, i := range keyIndexes {
+			key = append(key, row[i])
+		}
+		id := strings.Join(key, "\x00")
+		g, ok := groups[id]
+		if !ok {
+			g = &group{key: key, values: make([][]string, len(aggs))}
+			groups[id] = g
+			order = append(order, g)
+		}
+		for n, i := range aggIndexes {
+			value := ""
+			if i >= 0 {
+				value = row[i]
+			}
+			g.values[n] = append(g.values[n], value)
+			all[n] = append(all[n], value)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return compareRows(order[a].key, order[b].key) < 0
+	})
+
+	result := RawTable{Data: [][]string{}}
+	for _, i := range keyIndexes {
+		result.Headers = append(result.Headers, headers[i])
+	}
+	for _, a := range aggs {
+		result.Headers = append(result.Headers, a.header())
+	}
+	for _, g := range order {
+		row := append([]string{}, g.key...)
+		for n, reduce := range reducers {
+			row = append(row, reduce(g.values[n]))
+		}
+		result.Data = append(result.Data, row)
+	}
+	result.Footer = make([]string, len(keyIndexes))
+	if len(keyIndexes) > 0 {
+		result.Footer[0] = "Total"
+	}
+	for n, reduce := range reducers {
+		result.Footer = append(result.Footer, reduce(all[n]))
+	}
+	return result, nil
+}
+
+// Pivot summarises t in two dimensions, one row per distinct rowKey value and one column per distinct colKey value
+// Each cell holds agg over the matching rows, a Total column and footer hold agg over each row, column and the whole table
+// e.g. Pivot(instances, "Deployment", "State", Aggregate{Func: "count"})
+func Pivot(t Table, rowKey string, colKey string, agg Aggregate) (Table, error) {
+	reduce, err := agg.reducer()
+	if err != nil {
+		return nil, err
+	}
+	headers := t.TableHeaders()
+	indexes, err := columnIndexes(headers, []string{rowKey, colKey})
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not pivot")
+	}
+	valueIndex := -1
+	if agg.Column != "" {
+		if valueIndex, err = columnIndex(headers, agg.Column); err != nil {
+			return nil, errors.WithMessage(err, "could not pivot")
+		}
+	}
+
+	cells := map[[2]string][]string{}
+	byRow := map[string][]string{}
+	byCol := map[string][]string{}
+	var all []string
+	for _, row := range t.TableData() {
+		row = padRow(row, len(headers))
+		r, c := row[indexes[0]], row[indexes[1]]
+		value := ""
+		if valueIndex >= 0 {
+			value = row[valueIndex]
+		}
+		cells[[2]string{r, c}] = append(cells[[2]string{r, c}], value)
+		byRow[r] = append(byRow[r], value)
+		byCol[c] = append(byCol[c], value)
+		all = append(all, value)
+	}
+	rows := sortedValues(byRow)
+	cols := sortedValues(byCol)
+
+	result := RawTable{Headers: append(append([]string{headers[indexes[0]]}, cols...), "Total"), Data: [][]string{}}
+	for _, r := range rows {
+		row := []string{r}
+		for _, c := range cols {
+			values, ok := cells[[2]string{r, c}]
+			if !ok {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, reduce(values))
+		}
+		result.Data = append(result.Data, append(row, reduce(byRow[r])))
+	}
+	result.Footer = []string{"Total"}
+	for _, c := range cols {
+		result.Footer = append(result.Footer, reduce(byCol[c]))
+	}
+	result.Footer = append(result.Footer, reduce(all))
+	return result, nil
+}
+
+// WithTotals returns t with a footer holding the sum of each of the named columns
+func WithTotals(t Table, columns ...string) (Table, error) {
+	headers := t.TableHeaders()
+	indexes, err := columnIndexes(headers, columns)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not total")
+	}
+	values := make([][]string, len(headers))
+	for _, row := range t.TableData() {
+		row = padRow(row, len(headers))
+		for _, i := range indexes {
+			values[i] = append(values[i], row[i])
+		}
+	}
+	footer := make([]string, len(headers))
+	for _, i := range indexes {
+		sum, _ := sumValues(values[i])
+		footer[i] = formatNumber(sum)
+	}
+	if len(footer) > 0 && footer[0] == "" {
+		footer[0] = "Total"
+	}
+	return RawTable{Headers: headers, Data: t.TableData(), Footer: footer}, nil
+}
+
+// columnIndexes finds several headers case insensitively
+func columnIndexes(headers []string, columns []string) ([]int, error) {
+	var indexes []int
+	for _, c := range columns {
+		i, err := columnIndex(headers, c)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// compareRows compares two rows cell by cell
+func compareRows(a, b []string) int {
+	for i := range a {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// sortedValues returns the keys of a map in the order compareValues gives
+func sortedValues(m map[string][]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		return compareValues(keys[a], keys[b]) < 0
+	})
+	return keys
+}
@@ -0,0 +1,127 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAggregate(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   Aggregate
+		header string
+		err    string
+	}{
+		{in: "count", want: Aggregate{Func: "count"}, header: "count"},
+		{in: "COUNT()", want: Aggregate{Func: "count"}, header: "count"},
+		{in: " sum( Count ) ", want: Aggregate{Func: "sum", Column: "Count"}, header: "sum(Count)"},
+		{in: "avg(Count)", want: Aggregate{Func: "avg", Column: "Count"}, header: "avg(Count)"},
+		{in: "sum", err: "needs a column"},
+		{in: "median(Count)", err: "unknown aggregate"},
+		{in: "sum(Count", err: "invalid aggregate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseAggregate(tt.in)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ParseAggregate() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAggregate() error = %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAggregate() = %+v, want %+v", got, tt.want)
+			}
+			if got.header() != tt.header {
+				t.Errorf("header() = %q, want %q", got.header(), tt.header)
+			}
+		})
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	got, err := GroupBy(instancesTable, []string{"state"},
+		Aggregate{Func: "count"},
+		Aggregate{Func: "sum", Column: "Count"},
+		Aggregate{Func: "avg", Column: "Count", Header: "mean"},
+		Aggregate{Func: "max", Column: "Count"})
+	if err != nil {
+		t.Fatalf("GroupBy() error = %s", err)
+	}
+	if headers := strings.Join(got.TableHeaders(), ","); headers != "State,count,sum(Count),mean,max(Count)" {
+		t.Errorf("headers = %q", headers)
+	}
+	//groups are sorted by key and keys are case sensitive
+	if rows := rowsString(got); rows != "Running:1:2:2:2,running:2:19:9.5:10,stopped:1:2:2:2" {
+		t.Errorf("rows = %q", rows)
+	}
+	if footer := strings.Join(tableFooter(got), ":"); footer != "Total:4:23:5.75:10" {
+		t.Errorf("footer = %q, want Total:4:23:5.75:10", footer)
+	}
+}
+
+func TestGroupByDefaultsToCount(t *testing.T) {
+	got, err := GroupBy(instancesTable, nil)
+	if err != nil {
+		t.Fatalf("GroupBy() error = %s", err)
+	}
+	if rows := rowsString(got); rows != "4" {
+		t.Errorf("rows = %q, want a single count of 4", rows)
+	}
+}
+
+func TestGroupByErrors(t *testing.T) {
+	if _, err := GroupBy(instancesTable, []string{"Zone"}); err == nil || !strings.Contains(err.Error(), "could not group") {
+		t.Errorf("GroupBy() unknown key error = %v", err)
+	}
+	if _, err := GroupBy(instancesTable, nil, Aggregate{Func: "sum", Column: "Zone"}); err == nil ||
+		!strings.Contains(err.Error(), "could not aggregate") {
+		t.Errorf("GroupBy() unknown column error = %v", err)
+	}
+}
+
+func TestPivot(t *testing.T) {
+	table := RawTable{Headers: []string{"Deployment", "State", "Count"}, Data: [][]string{
+		{"prod", "running", "3"},
+		{"prod", "stopped", "1"},
+		{"dev", "running", "2"},
+		{"prod", "running", "4"},
+	}}
+	got, err := Pivot(table, "Deployment", "State", Aggregate{Func: "sum", Column: "Count"})
+	if err != nil {
+		t.Fatalf("Pivot() error = %s", err)
+	}
+	if headers := strings.Join(got.TableHeaders(), ","); headers != "Deployment,running,stopped,Total" {
+		t.Errorf("headers = %q", headers)
+	}
+	//dev has no stopped rows so that cell is empty rather than 0
+	if rows := rowsString(got); rows != "dev:2::2,prod:7:1:8" {
+		t.Errorf("rows = %q", rows)
+	}
+	if footer := strings.Join(tableFooter(got), ":"); footer != "Total:9:1:10" {
+		t.Errorf("footer = %q, want Total:9:1:10", footer)
+	}
+
+	if _, err := Pivot(table, "Deployment", "Zone", Aggregate{Func: "count"}); err == nil {
+		t.Error("Pivot() on an unknown column did not fail")
+	}
+}
+
+func TestWithTotals(t *testing.T) {
+	got, err := WithTotals(instancesTable, "count")
+	if err != nil {
+		t.Fatalf("WithTotals() error = %s", err)
+	}
+	if rows := rowsString(got); rows != rowsString(instancesTable) {
+		t.Errorf("rows = %q, want them unchanged", rows)
+	}
+	if footer := strings.Join(tableFooter(got), ":"); footer != "Total::23" {
+		t.Errorf("footer = %q, want Total::23", footer)
+	}
+	if _, err := WithTotals(instancesTable, "Zone"); err == nil {
+		t.Error("WithTotals() on an unknown column did not fail")
+	}
+}
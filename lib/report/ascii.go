@@ -0,0 +1,505 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"golang.org/x/term"
+)
+
+// Overflow says what the ASCII renderer does with cells wider than their column
+type Overflow int
+
+const (
+	// Truncate cuts long cells short and ends them with an ellipsis
+	Truncate Overflow = iota
+	// Wrap breaks long cells over several lines, at spaces where possible
+	Wrap
+)
+
+// Layout says how the ASCII renderer arranges rows
+type Layout int
+
+const (
+	// LayoutAuto draws a table, switching to records when the table cannot fit the width
+	LayoutAuto Layout = iota
+	// LayoutTable always draws a table
+	LayoutTable
+	// LayoutRecord draws every row as a vertical list of header and value pairs
+	LayoutRecord
+)
+
+// minColumnWidth is the narrowest a column is shrunk to when fitting a table to the width
+const minColumnWidth = 6
+
+// ASCIIRenderer draws a bordered table, this is the format OutputTable has always used
+// Width is the widest a line may be, 0 uses the terminal width when writing to a terminal and no limit otherwise
+// and a negative Width never limits, even when writing to a terminal
+// MaxWidths caps single columns by header, cells over their column's width are truncated or wrapped as Overflow says
+// SampleRows and SampleTimeout only apply to RenderStream, see there
+type ASCIIRenderer struct {
+	Width         int
+	MaxWidths     map[string]int
+	Overflow      Overflow
+	Layout        Layout
+	SampleRows    int
+	SampleTimeout time.Duration
+}
+
+// Render implements Renderer
+func (r ASCIIRenderer) Render(w io.Writer, t Table) error {
+	headers := t.TableHeaders()
+	var rows [][]string
+	for _, row := range t.TableData() {
+		rows = append(rows, padRow(row, len(headers)))
+	}
+	footer := tableFooter(t)
+
+	limit := r.Width
+	if limit == 0 {
+		limit = terminalWidth(w)
+	}
+	widths, fits := r.columnWidths(headers, append(append([][]string{}, rows...), footer), limit)
+	if r.Layout == LayoutRecord || (r.Layout == LayoutAuto && !fits) {
+		return r.renderRecords(w, headers, rows, limit)
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetHeader(r.fitRow(headers, widths, Truncate))
+	for _, row := range rows {
+		table.Append(r.fitRow(row, widths, r.Overflow))
+	}
+	if footer != nil {
+		//tablewriter drops the borders around empty footer cells
+		cells := r.fitRow(footer, widths, Truncate)
+		for i, cell := range cells {
+			if cell == "" {
+				cells[i] = " "
+			}
+		}
+		table.SetFooter(cells)
+	}
+	table.Render()
+	return nil
+}
+
+// renderRecords draws each row as a block of header | value lines, like psql's expanded display
+func (r ASCIIRenderer) renderRecords(w io.Writer, headers []string, rows [][]string, limit int) error {
+	layout := r.recordLayout(headers, rows, limit)
+	for n, row := range rows {
+		if err := layout.write(w, n+1, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// records holds what is needed to draw rows in the record layout
+type records struct {
+	headers    []string
+	label      int
+	valueWidth int
+	rule       int
+	overflow   Overflow
+}
+
+// recordLayout measures headers and rows for the record layout, valueWidth is 0 when there is no width limit
+func (r ASCIIRenderer) recordLayout(headers []string, rows [][]string, limit int) records {
+	layout := records{headers: headers, overflow: r.Overflow}
+	for _, h := range headers {
+		if width := tablewriter.DisplayWidth(h); width > layout.label {
+			layout.label = width
+		}
+	}
+	if limit > 0 {
+		layout.valueWidth = limit - layout.label - 3
+		if layout.valueWidth < minColumnWidth {
+			layout.valueWidth = minColumnWidth
+		}
+	}
+	layout.rule = layout.valueWidth
+	if layout.rule == 0 {
+		for _, row := range rows {
+			for _, cell := range row {
+				if width := cellWidth(cell); width > layout.rule {
+					layout.rule = width
+				}
+			}
+		}
+	}
+	layout.rule += layout.label + 3
+	return layout
+}
+
+// write draws the nth record
+func (l records) write(w io.Writer, record int, row []string) error {
+	title := fmt.Sprintf("-[ RECORD %d ]", record)
+	if pad := l.rule - len(title); pad > 0 {
+		title += strings.Repeat("-", pad)
+	}
+	if _, err := fmt.Fprintln(w, title); err != nil {
+		return err
+	}
+	for i, h := range l.headers {
+		value := row[i]
+		if l.valueWidth > 0 {
+			value = fitCell(value, l.valueWidth, l.overflow)
+		}
+		for n, line := range strings.Split(value, "\n") {
+			name := h
+			if n > 0 {
+				name = ""
+			}
+			if _, err := fmt.Fprintf(w, "%s | %s\n", tablewriter.PadRight(name, " ", l.label), line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RenderStream implements StreamRenderer
+// Column widths are fixed from the first SampleRows rows, 0 means 50, and drawing starts early with the rows read so far
+// once SampleTimeout has passed when it is set. Later cells wider than their column are truncated or wrapped as
+// Overflow says. The table is drawn in the style of tablewriter without holding every row in memory
+func (r ASCIIRenderer) RenderStream(w io.Writer, s Stream) error {
+	headers := s.TableHeaders()
+	rows := pumpRows(s.Rows())
+	defer rows.stop()
+	size := r.SampleRows
+	if size < 1 {
+		size = streamSampleRows
+	}
+	var timeout <-chan time.Time
+	if r.SampleTimeout > 0 {
+		timer := time.NewTimer(r.SampleTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var sample [][]string
+sampling:
+	for len(sample) < size {
+		select {
+		case row, ok := <-rows.rows:
+			if !ok {
+				if rows.err != nil {
+					return rows.err
+				}
+				break sampling
+			}
+			sample = append(sample, padRow(row, len(headers)))
+		case <-timeout:
+			break sampling
+		}
+	}
+
+	limit := r.Width
+	if limit == 0 {
+		limit = terminalWidth(w)
+	}
+	widths, fits := r.columnWidths(headers, sample, limit)
+	write := func(n int, row []string) error {
+		return writeStreamRow(w, r.fitRow(row, widths, r.Overflow), widths)
+	}
+	table := !(r.Layout == LayoutRecord || (r.Layout == LayoutAuto && !fits))
+	if !table {
+		layout := r.recordLayout(headers, sample, limit)
+		write = func(n int, row []string) error {
+			return layout.write(w, n, row)
+		}
+	} else {
+		var title []string
+		for _, h := range r.fitRow(headers, widths, Truncate) {
+			title = append(title, tablewriter.Title(h))
+		}
+		if err := writeStreamBorder(w, widths); err != nil {
+			return err
+		}
+		if err := writeStreamHeader(w, title, widths); err != nil {
+			return err
+		}
+		if err := writeStreamBorder(w, widths); err != nil {
+			return err
+		}
+	}
+
+	n := 0
+	for _, row := range sample {
+		n++
+		if err := write(n, row); err != nil {
+			return err
+		}
+	}
+	for row := range rows.rows {
+		n++
+		if err := write(n, padRow(row, len(headers))); err != nil {
+			return err
+		}
+	}
+	if table {
+		if err := writeStreamBorder(w, widths); err != nil {
+			return err
+		}
+	}
+	return rows.err
+}
+
+// pumpedRows reads Rows in its own goroutine so a reader can stop waiting for them, e.g. when a sample times out
+// err is the rows' error and may only be read once rows is closed
+type pumpedRows struct {
+	rows chan []string
+	done chan struct{}
+	err  error
+}
+
+// pumpRows starts reading rows, they are closed once read to the end or once stop is called
+func pumpRows(rows Rows) *pumpedRows {
+	p := &pumpedRows{rows: make(chan []string), done: make(chan struct{})}
+	go func() {
+		defer close(p.rows)
+		defer rows.Close()
+		for rows.Next() {
+			select {
+			case p.rows <- rows.Row():
+			case <-p.done:
+				return
+			}
+		}
+		p.err = rows.Err()
+	}()
+	return p
+}
+
+// stop tells the reading goroutine to close the rows and waits for it to finish
+func (p *pumpedRows) stop() {
+	close(p.done)
+	for range p.rows {
+	}
+}
+
+// writeStreamBorder draws a +---+ line
+func writeStreamBorder(w io.Writer, widths []int) error {
+	line := "+"
+	for _, width := range widths {
+		line += strings.Repeat("-", width+2) + "+"
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+// writeStreamHeader draws the header line with centered titles
+func writeStreamHeader(w io.Writer, headers []string, widths []int) error {
+	line := "|"
+	for i, h := range headers {
+		line += " " + tablewriter.Pad(h, " ", widths[i]) + " |"
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+// writeStreamRow draws a row, multi line cells make the row several lines tall
+// numbers are aligned right and everything else left, as tablewriter does
+func writeStreamRow(w io.Writer, row []string, widths []int) error {
+	cells := make([][]string, len(row))
+	height := 1
+	for i, cell := range row {
+		cells[i] = strings.Split(cell, "\n")
+		if len(cells[i]) > height {
+			height = len(cells[i])
+		}
+	}
+	for l := 0; l < height; l++ {
+		line := "|"
+		for i, lines := range cells {
+			text := ""
+			if l < len(lines) {
+				text = lines[l]
+			}
+			if _, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+				text = tablewriter.PadLeft(text, " ", widths[i])
+			} else {
+				text = tablewriter.PadRight(text, " ", widths[i])
+			}
+			line += " " + text + " |"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnWidths works out how wide each column may be, shrinking the widest columns until the table fits limit
+// it reports false when the table is still too wide with every column at its minimum
+func (r ASCIIRenderer) columnWidths(headers []string, rows [][]string, limit int) ([]int, bool) {
+	widths := make([]int, len(headers))
+	for i, h := range headers {
+		widths[i] = cellWidth(h)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if i < len(widths) && cellWidth(cell) > widths[i] {
+				widths[i] = cellWidth(cell)
+			}
+		}
+	}
+	for i, h := range headers {
+		if max := r.maxWidth(h); max > 0 && widths[i] > max {
+			widths[i] = max
+		}
+	}
+	if limit <= 0 {
+		return widths, true
+	}
+	//each column has a space either side and a border, plus the border at the start of the line
+	total := func() int {
+		sum := 1
+		for _, width := range widths {
+			sum += width + 3
+		}
+		return sum
+	}
+	for total() > limit {
+		widest := 0
+		for i := range widths {
+			if widths[i] > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumnWidth {
+			return widths, false
+		}
+		widths[widest]--
+	}
+	return widths, true
+}
+
+// maxWidth returns the configured maximum width of a column, or 0 when it has none
+func (r ASCIIRenderer) maxWidth(header string) int {
+	for name, width := range r.MaxWidths {
+		if strings.EqualFold(name, header) {
+			return width
+		}
+	}
+	return 0
+}
+
+// fitRow fits every cell of a row to its column's width
+func (r ASCIIRenderer) fitRow(row []string, widths []int, overflow Overflow) []string {
+	fitted := make([]string, len(row))
+	for i, cell := range row {
+		fitted[i] = fitCell(cell, widths[i], overflow)
+	}
+	return fitted
+}
+
+// fitCell truncates or wraps a cell to width, every line of a multi line cell is fitted separately
+func fitCell(cell string, width int, overflow Overflow) string {
+	if cellWidth(cell) <= width {
+		return cell
+	}
+	var lines []string
+	for _, line := range strings.Split(cell, "\n") {
+		if overflow == Wrap {
+			lines = append(lines, wrapLine(line, width)...)
+			continue
+		}
+		lines = append(lines, truncateLine(line, width))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cellWidth is the display width of the widest line of a cell
+func cellWidth(cell string) int {
+	widest := 0
+	for _, line := range strings.Split(cell, "\n") {
+		if width := tablewriter.DisplayWidth(line); width > widest {
+			widest = width
+		}
+	}
+	return widest
+}
+
+// truncateLine cuts a line to width display columns, ending it with an ellipsis when anything was cut
+func truncateLine(line string, width int) string {
+	if tablewriter.DisplayWidth(line) <= width {
+		return line
+	}
+	var b strings.Builder
+	used := 0
+	for _, r := range line {
+		rw := tablewriter.DisplayWidth(string(r))
+		if used+rw > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		used += rw
+	}
+	return b.String() + "…"
+}
+
+// wrapLine breaks a line into lines of at most width display columns, words longer than width are split
+func wrapLine(line string, width int) []string {
+	var lines []string
+	current := ""
+	for _, word := range strings.Fields(line) {
+		for tablewriter.DisplayWidth(word) > width {
+			if current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			head, rest := splitAtWidth(word, width)
+			lines = append(lines, head)
+			word = rest
+		}
+		switch {
+		case current == "":
+			current = word
+		case tablewriter.DisplayWidth(current)+1+tablewriter.DisplayWidth(word) <= width:
+			current += " " + word
+		default:
+			lines = append(lines, current)
+			current = word
+		}
+	}
+	if current != "" || len(lines) == 0 {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// splitAtWidth splits s after the last rune which fits in width display columns
+func splitAtWidth(s string, width int) (string, string) {
+	used := 0
+	for i, r := range s {
+		rw := tablewriter.DisplayWidth(string(r))
+		if used+rw > width {
+			if i == 0 {
+				//a single rune wider than the column still has to go somewhere
+				return string(r), s[len(string(r)):]
+			}
+			return s[:i], s[i:]
+		}
+		used += rw
+	}
+	return s, ""
+}
+
+// terminalWidth returns the width of the terminal w writes to, or 0 when w is not a terminal
+func terminalWidth(w io.Writer) int {
+	f, ok := w.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return 0
+	}
+	width, _, err := term.GetSize(int(f.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
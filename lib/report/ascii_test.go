@@ -0,0 +1,179 @@
+package report
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// describedTable has one cell too long for the narrow widths the tests use
+var describedTable = RawTable{Headers: []string{"Name", "Description"}, Data: [][]string{
+	{"web", "a very long description that overflows"},
+	{"db", "short"},
+}}
+
+func TestASCIIRendererRender(t *testing.T) {
+	wide := RawTable{Headers: []string{"Alpha", "Bravo", "Charlie", "Delta"}, Data: [][]string{{"1234567890", "x", "abcdefghij", "2"}}}
+	tests := []struct {
+		name     string
+		renderer ASCIIRenderer
+		table    Table
+		want     string
+	}{
+		{
+			name:     "truncated to the width",
+			renderer: ASCIIRenderer{Width: 30},
+			table:    describedTable,
+			want: "+------+---------------------+\n" +
+				"| NAME |     DESCRIPTION     |\n" +
+				"+------+---------------------+\n" +
+				"| web  | a very long descri… |\n" +
+				"| db   | short               |\n" +
+				"+------+---------------------+\n",
+		},
+		{
+			name:     "wrapped to the width",
+			renderer: ASCIIRenderer{Width: 30, Overflow: Wrap},
+			table:    describedTable,
+			want: "+------+------------------+\n" +
+				"| NAME |   DESCRIPTION    |\n" +
+				"+------+------------------+\n" +
+				"| web  | a very long      |\n" +
+				"|      | description that |\n" +
+				"|      | overflows        |\n" +
+				"| db   | short            |\n" +
+				"+------+------------------+\n",
+		},
+		{
+			name:     "max widths without a width limit",
+			renderer: ASCIIRenderer{Width: -1, MaxWidths: map[string]int{"description": 10}},
+			table:    describedTable,
+			want: "+------+------------+\n" +
+				"| NAME | DESCRIPTI… |\n" +
+				"+------+------------+\n" +
+				"| web  | a very lo… |\n" +
+				"| db   | short      |\n" +
+				"+------+------------+\n",
+		},
+		{
+			name:     "fits exactly",
+			renderer: ASCIIRenderer{Width: 20},
+			table:    describedTable,
+			want: "+------+-----------+\n" +
+				"| NAME | DESCRIPT… |\n" +
+				"+------+-----------+\n" +
+				"| web  | a very l… |\n" +
+				"| db   | short     |\n" +
+				"+------+-----------+\n",
+		},
+		{
+			name:     "too wide at the minimum column width falls back to records",
+			renderer: ASCIIRenderer{Width: 20},
+			table:    wide,
+			want: "-[ RECORD 1 ]-------\n" +
+				"Alpha   | 1234567890\n" +
+				"Bravo   | x\n" +
+				"Charlie | abcdefghij\n" +
+				"Delta   | 2\n",
+		},
+		{
+			name:     "a forced table stops shrinking at the minimum column width",
+			renderer: ASCIIRenderer{Width: 20, Layout: LayoutTable},
+			table:    wide,
+			want: "+--------+-------+--------+-------+\n" +
+				"| ALPHA  | BRAVO | CHARL… | DELTA |\n" +
+				"+--------+-------+--------+-------+\n" +
+				"| 12345… | x     | abcde… |     2 |\n" +
+				"+--------+-------+--------+-------+\n",
+		},
+		{
+			name:     "records",
+			renderer: ASCIIRenderer{Width: -1, Layout: LayoutRecord},
+			table:    describedTable,
+			want: "-[ RECORD 1 ]---------------------------------------\n" +
+				"Name        | web\n" +
+				"Description | a very long description that overflows\n" +
+				"-[ RECORD 2 ]---------------------------------------\n" +
+				"Name        | db\n" +
+				"Description | short\n",
+		},
+		{
+			name:     "records wrapped to the width",
+			renderer: ASCIIRenderer{Width: 20, Layout: LayoutRecord, Overflow: Wrap},
+			table:    describedTable,
+			want: "-[ RECORD 1 ]-------\n" +
+				"Name        | web\n" +
+				"Description | a very\n" +
+				"            | long\n" +
+				"            | descri\n" +
+				"            | ption\n" +
+				"            | that\n" +
+				"            | overfl\n" +
+				"            | ows\n" +
+				"-[ RECORD 2 ]-------\n" +
+				"Name        | db\n" +
+				"Description | short\n",
+		},
+		{
+			name:     "short footer",
+			renderer: ASCIIRenderer{Width: -1},
+			table:    RawTable{Headers: []string{"Name", "Count"}, Data: [][]string{{"web", "2"}}, Footer: []string{"Total"}},
+			want: "+-------+-------+\n" +
+				"| NAME  | COUNT |\n" +
+				"+-------+-------+\n" +
+				"| web   |     2 |\n" +
+				"+-------+-------+\n" +
+				"| TOTAL |       |\n" +
+				"+-------+-------+\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.renderer.Render(&buf, tt.table); err != nil {
+				t.Fatalf("Render() error = %s", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Render() =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		width int
+		want  string
+	}{
+		{line: "short", width: 6, want: "short"},
+		{line: "exactly", width: 7, want: "exactly"},
+		{line: "too long", width: 6, want: "too l…"},
+		//wide runes take two columns so fewer of them fit
+		{line: "日本語テキスト", width: 6, want: "日本…"},
+	}
+	for _, tt := range tests {
+		if got := truncateLine(tt.line, tt.width); got != tt.want {
+			t.Errorf("truncateLine(%q, %d) = %q, want %q", tt.line, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestWrapLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		width int
+		want  []string
+	}{
+		{line: "", width: 6, want: []string{""}},
+		{line: "a b c", width: 3, want: []string{"a b", "c"}},
+		{line: "one  two", width: 6, want: []string{"one", "two"}},
+		{line: "a abcdefgh", width: 4, want: []string{"a", "abcd", "efgh"}},
+		{line: "日本語", width: 4, want: []string{"日本", "語"}},
+	}
+	for _, tt := range tests {
+		if got := wrapLine(tt.line, tt.width); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("wrapLine(%q, %d) = %q, want %q", tt.line, tt.width, got, tt.want)
+		}
+	}
+}
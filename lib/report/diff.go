@@ -0,0 +1,335 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+)
+
+// RowStatus says how a row differs between two versions of a table
+type RowStatus string
+
+// The row statuses of a TableDiff
+const (
+	RowUnchanged RowStatus = " "
+	RowAdded     RowStatus = "+"
+	RowRemoved   RowStatus = "-"
+	RowChanged   RowStatus = "~"
+)
+
+// RowDiff is a single keyed row in both versions of a table
+// Before and After are aligned to TableDiff.Columns and are nil when the row is missing from that version
+type RowDiff struct {
+	Key    []string
+	Status RowStatus
+	Before []string
+	After  []string
+}
+
+// TableDiff compares two versions of a table row by row, rows are matched on the key columns
+// It implements Table as a side by side view: a status column, the key columns, then a before and after column for
+// every other column. Changed cells are marked with - and + so the plain renderers show what changed
+type TableDiff struct {
+	Keys    []string
+	Columns []string
+	Rows    []RowDiff
+	//oneSided marks the Columns present in only one version, nil when every column is in both
+	oneSided []bool
+}
+
+// DiffTables compares before and after, matching rows on the key columns
+// Columns only present in one version are shown empty in the other and do not make a row changed
+func DiffTables(before Table, after Table, keys ...string) (TableDiff, error) {
+	if len(keys) == 0 {
+		return TableDiff{}, errors.New("could not diff tables, at least one key column is required")
+	}
+	beforeHeaders := before.TableHeaders()
+	afterHeaders := after.TableHeaders()
+
+	d := TableDiff{}
+	for _, k := range keys {
+		i, err := columnIndex(beforeHeaders, k)
+		if err != nil {
+			return TableDiff{}, errors.WithMessage(err, "could not diff tables, key missing from before")
+		}
+		if _, err := columnIndex(afterHeaders, k); err != nil {
+			return TableDiff{}, errors.WithMessage(err, "could not diff tables, key missing from after")
+		}
+		d.Keys = append(d.Keys, beforeHeaders[i])
+	}
+	for _, h := range append(append([]string{}, beforeHeaders...), afterHeaders...) {
+		if _, err := columnIndex(d.Keys, h); err == nil {
+			continue
+		}
+		if _, err := columnIndex(d.Columns, h); err == nil {
+			continue
+		}
+		d.Columns = append(d.Columns, h)
+	}
+	for _, c := range d.Columns {
+		_, errBefore := columnIndex(beforeHeaders, c)
+		_, errAfter := columnIndex(afterHeaders, c)
+		d.oneSided = append(d.oneSided, errBefore != nil || errAfter != nil)
+	}
+
+	beforeRows, err := keyedRows(before, d.Keys, d.Columns)
+	if err != nil {
+		return TableDiff{}, errors.WithMessage(err, "could not diff before table")
+	}
+	afterRows, err := keyedRows(after, d.Keys, d.Columns)
+	if err != nil {
+		return TableDiff{}, errors.WithMessage(err, "could not diff after table")
+	}
+
+	rows := map[string]*RowDiff{}
+	for id, r := range beforeRows {
+		rows[id] = &RowDiff{Key: r.key, Status: RowRemoved, Before: r.values}
+	}
+	for id, r := range afterRows {
+		row, ok := rows[id]
+		if !ok {
+			rows[id] = &RowDiff{Key: r.key, Status: RowAdded, After: r.values}
+			continue
+		}
+		row.After = r.values
+		row.Status = RowUnchanged
+		for i := range row.Before {
+			if d.compared(i) && row.Before[i] != row.After[i] {
+				row.Status = RowChanged
+				break
+			}
+		}
+	}
+	for _, row := range rows {
+		d.Rows = append(d.Rows, *row)
+	}
+	sort.Slice(d.Rows, func(a, b int) bool {
+		return compareRows(d.Rows[a].Key, d.Rows[b].Key) < 0
+	})
+	return d, nil
+}
+
+// keyedRow is a row split into its key and its other columns
+type keyedRow struct {
+	key    []string
+	values []string
+}
+
+// keyedRows indexes the rows of t by their key, values are aligned to columns
+func keyedRows(t Table, keys []string, columns []string) (map[string]keyedRow, error) {
+	headers := t.TableHeaders()
+	keyIndexes, err := columnIndexes(headers, keys)
+	if err != nil {
+		return nil, err
+	}
+	valueIndexes := make([]int, len(columns))
+	for n, c := range columns {
+		valueIndexes[n], _ = columnIndex(headers, c)
+	}
+	rows := map[string]keyedRow{}
+	for _, row := range t.TableData() {
+		row = padRow(row, len(headers))
+		r := keyedRow{}
+		for _, i := range keyIndexes {
+			r.key = append(r.key, row[i])
+		}
+		for _, i := range valueIndexes {
+			value := ""
+			if i >= 0 {
+				value = row[i]
+			}
+			r.values = append(r.values, value)
+		}
+		id := strings.Join(r.key, "\x00")
+		if _, ok := rows[id]; ok {
+			return nil, errors.Errorf("duplicate key %s", strings.Join(r.key, ", "))
+		}
+		rows[id] = r
+	}
+	return rows, nil
+}
+
+// Changes returns the diff without its unchanged rows
+func (d TableDiff) Changes() TableDiff {
+	changes := TableDiff{Keys: d.Keys, Columns: d.Columns, oneSided: d.oneSided}
+	for _, row := range d.Rows {
+		if row.Status != RowUnchanged {
+			changes.Rows = append(changes.Rows, row)
+		}
+	}
+	return changes
+}
+
+// compared reports whether the ith column is in both versions, so a difference in it changes the row
+func (d TableDiff) compared(i int) bool {
+	return i >= len(d.oneSided) || !d.oneSided[i]
+}
+
+// HasChanges reports whether any row was added, removed or changed
+func (d TableDiff) HasChanges() bool {
+	return len(d.Changes().Rows) > 0
+}
+
+// cellKind says how a single cell of the side by side view should be highlighted
+type cellKind int
+
+const (
+	cellSame cellKind = iota
+	cellAdded
+	cellRemoved
+)
+
+// diffCell is a single cell of the side by side view
+type diffCell struct {
+	text string
+	kind cellKind
+}
+
+// TableHeaders returns the headers of the side by side view
+func (d TableDiff) TableHeaders() []string {
+	headers := append([]string{""}, d.Keys...)
+	for _, c := range d.Columns {
+		headers = append(headers, c+" (before)", c+" (after)")
+	}
+	return headers
+}
+
+// TableData returns the rows of the side by side view, changed cells are prefixed with - and +
+func (d TableDiff) TableData() [][]string {
+	var data [][]string
+	for _, row := range d.grid() {
+		var cells []string
+		for _, cell := range row {
+			cells = append(cells, cell.text)
+		}
+		data = append(data, cells)
+	}
+	return data
+}
+
+// grid lays out the side by side view with the highlighting of every cell
+func (d TableDiff) grid() [][]diffCell {
+	var grid [][]diffCell
+	for _, row := range d.Rows {
+		kind := cellSame
+		switch row.Status {
+		case RowAdded:
+			kind = cellAdded
+		case RowRemoved:
+			kind = cellRemoved
+		}
+		cells := []diffCell{{text: string(row.Status), kind: kind}}
+		for _, k := range row.Key {
+			cells = append(cells, diffCell{text: k, kind: kind})
+		}
+		for i := range d.Columns {
+			before, after := diffCell{kind: kind}, diffCell{kind: kind}
+			if row.Before != nil {
+				before.text = row.Before[i]
+			}
+			if row.After != nil {
+				after.text = row.After[i]
+			}
+			if row.Status == RowChanged && d.compared(i) && row.Before[i] != row.After[i] {
+				before = diffCell{text: "-" + row.Before[i], kind: cellRemoved}
+				after = diffCell{text: "+" + row.After[i], kind: cellAdded}
+			}
+			cells = append(cells, before, after)
+		}
+		grid = append(grid, cells)
+	}
+	return grid
+}
+
+// ANSIDiffRenderer draws a TableDiff as an ASCII table with added cells in green and removed cells in red
+// Tables which are not a TableDiff are drawn as ASCIIRenderer would
+type ANSIDiffRenderer struct{}
+
+// HTMLDiffRenderer writes a TableDiff as a standalone HTML document with added and removed cells styled
+// Tables which are not a TableDiff are written as HTMLRenderer would
+type HTMLDiffRenderer struct {
+	Title string
+}
+
+// ansi escape codes used by ANSIDiffRenderer
+const (
+	ansiReset = "\x1b[0m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+)
+
+// Render implements Renderer
+func (r ANSIDiffRenderer) Render(w io.Writer, t Table) error {
+	d, ok := t.(TableDiff)
+	if !ok {
+		return ASCIIRenderer{}.Render(w, t)
+	}
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(false)
+	//colored numbers are no longer recognised as numbers, so align everything the same way
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader(d.TableHeaders())
+	for _, row := range d.grid() {
+		var cells []string
+		for _, cell := range row {
+			switch {
+			case cell.text == "":
+				cells = append(cells, "")
+			case cell.kind == cellAdded:
+				cells = append(cells, ansiGreen+cell.text+ansiReset)
+			case cell.kind == cellRemoved:
+				cells = append(cells, ansiRed+cell.text+ansiReset)
+			default:
+				cells = append(cells, cell.text)
+			}
+		}
+		table.Append(cells)
+	}
+	table.Render()
+	return nil
+}
+
+// Render implements Renderer
+func (r HTMLDiffRenderer) Render(w io.Writer, t Table) error {
+	d, ok := t.(TableDiff)
+	if !ok {
+		return HTMLRenderer{Title: r.Title}.Render(w, t)
+	}
+	bw := bufio.NewWriter(w)
+	title := r.Title
+	if title == "" {
+		title = "Diff"
+	}
+	writeHTMLHead(bw, title, "td.added{background:#e6ffed;color:#22863a}\ntd.removed{background:#ffeef0;color:#b31d28}\n")
+	bw.WriteString("<table>\n<thead>\n<tr>")
+	for _, h := range d.TableHeaders() {
+		fmt.Fprintf(bw, "<th>%s</th>", html.EscapeString(h))
+	}
+	bw.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for _, row := range d.grid() {
+		bw.WriteString("<tr>")
+		for _, cell := range row {
+			switch cell.kind {
+			case cellAdded:
+				bw.WriteString(`<td class="added">`)
+			case cellRemoved:
+				bw.WriteString(`<td class="removed">`)
+			default:
+				bw.WriteString("<td>")
+			}
+			bw.WriteString(html.EscapeString(cell.text))
+			bw.WriteString("</td>")
+		}
+		bw.WriteString("</tr>\n")
+	}
+	bw.WriteString("</tbody>\n</table>\n")
+	writeHTMLFoot(bw)
+	return bw.Flush()
+}
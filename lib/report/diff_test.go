@@ -0,0 +1,107 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiffTables(t *testing.T) {
+	before := RawTable{Headers: []string{"Name", "State", "Size"}, Data: [][]string{
+		{"web", "running", "2"},
+		{"db", "running", "1"},
+		{"cache", "stopped", "1"},
+	}}
+	after := RawTable{Headers: []string{"Name", "State", "Zone"}, Data: [][]string{
+		{"web", "running", "a"},
+		{"db", "stopped", ""},
+		{"queue", "running", "b"},
+	}}
+	d, err := DiffTables(before, after, "name")
+	if err != nil {
+		t.Fatalf("DiffTables() error = %s", err)
+	}
+	if strings.Join(d.Columns, ",") != "State,Size,Zone" {
+		t.Errorf("Columns = %q, want State,Size,Zone", d.Columns)
+	}
+	//Size and Zone are each in only one version so web, which differs only in them, is unchanged
+	want := map[string]RowStatus{"cache": RowRemoved, "db": RowChanged, "queue": RowAdded, "web": RowUnchanged}
+	var order []string
+	for _, row := range d.Rows {
+		order = append(order, row.Key[0])
+		if row.Status != want[row.Key[0]] {
+			t.Errorf("row %s status = %q, want %q", row.Key[0], row.Status, want[row.Key[0]])
+		}
+	}
+	if strings.Join(order, ",") != "cache,db,queue,web" {
+		t.Errorf("rows are in order %q, want them sorted by key", order)
+	}
+
+	data := d.TableData()
+	if got := strings.Join(data[1], "|"); got != "~|db|-running|+stopped|1|||" {
+		t.Errorf("db row = %q", got)
+	}
+	if got := strings.Join(data[3], "|"); got != " |web|running|running|2|||a" {
+		t.Errorf("web row = %q", got)
+	}
+	changes := d.Changes()
+	if !d.HasChanges() || len(changes.Rows) != 3 {
+		t.Errorf("Changes() = %d rows, want 3", len(changes.Rows))
+	}
+	if got := strings.Join(changes.TableData()[1], "|"); got != "~|db|-running|+stopped|1|||" {
+		t.Errorf("changed db row = %q, want one sided columns still unmarked", got)
+	}
+}
+
+func TestDiffTablesErrors(t *testing.T) {
+	table := RawTable{Headers: []string{"Name", "State"}, Data: [][]string{{"web", "running"}}}
+	duplicate := RawTable{Headers: []string{"Name", "State"}, Data: [][]string{{"web", "running"}, {"web", "stopped"}}}
+	tests := []struct {
+		name  string
+		after Table
+		keys  []string
+		err   string
+	}{
+		{name: "no keys", after: table, err: "at least one key column"},
+		{name: "missing key", after: table, keys: []string{"Zone"}, err: "key missing from before"},
+		{name: "duplicate key", after: duplicate, keys: []string{"Name"}, err: "duplicate key web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DiffTables(table, tt.after, tt.keys...)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("DiffTables() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+	same, err := DiffTables(table, table, "Name")
+	if err != nil || same.HasChanges() {
+		t.Errorf("diff of a table with itself = %+v, %v, want no changes", same, err)
+	}
+}
+
+func TestHTMLDiffRenderer(t *testing.T) {
+	before := RawTable{Headers: []string{"Name", "State"}, Data: [][]string{{"web", "running"}, {"db", "<up>"}}}
+	after := RawTable{Headers: []string{"Name", "State"}, Data: [][]string{{"web", "stopped"}}}
+	d, err := DiffTables(before, after, "Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := (HTMLDiffRenderer{Title: "Changes"}).Render(&buf, d); err != nil {
+		t.Fatalf("Render() error = %s", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"<title>Changes</title>",
+		"th{background:#f0f0f0}",
+		"td.added{",
+		`<td class="removed">-running</td><td class="added">+stopped</td>`,
+		`<td class="removed">&lt;up&gt;</td>`,
+		"</table>\n</body>\n</html>\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+}
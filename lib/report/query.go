@@ -0,0 +1,279 @@
+package report
+
+import (
+	"flag"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Query selects, filters and sorts the rows of a Table
+// Columns are matched to headers case insensitively, an empty Columns keeps every column
+type Query struct {
+	Columns []string
+	Sort    []SortKey
+	Filters []Filter
+}
+
+// SortKey sorts by a single column, values that are both numbers are compared as numbers
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+// Filter keeps the rows whose Column compares to Value with Op
+// Op is one of = != < <= > >= or ~ (case insensitive contains)
+type Filter struct {
+	Column string
+	Op     string
+	Value  string
+}
+
+// filterOps is ordered so two character operators are found before their one character prefixes
+var filterOps = []string{"!=", "<=", ">=", "=", "<", ">", "~"}
+
+// ParseColumns parses a comma separated list of column names e.g. Name,State
+func ParseColumns(s string) []string {
+	var columns []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// ParseSort parses a comma separated list of sort keys, a leading - sorts that column descending
+// e.g. -InstancesCount,Name
+func ParseSort(s string) []SortKey {
+	var keys []SortKey
+	for _, c := range ParseColumns(s) {
+		key := SortKey{Column: c}
+		if strings.HasPrefix(c, "-") {
+			key = SortKey{Column: strings.TrimPrefix(c, "-"), Desc: true}
+		} else if strings.HasPrefix(c, "+") {
+			key.Column = strings.TrimPrefix(c, "+")
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// ParseFilter parses an expression such as State=enabled or InstancesCount>=2
+func ParseFilter(s string) (Filter, error) {
+	best, bestAt := "", -1
+	for _, op := range filterOps {
+		if i := strings.Index(s, op); i > 0 && (bestAt == -1 || i < bestAt) {
+			best, bestAt = op, i
+		}
+	}
+	if bestAt == -1 {
+		return Filter{}, errors.Errorf("invalid filter %q, expected column, operator and value e.g. State=enabled", s)
+	}
+	return Filter{
+		Column: strings.TrimSpace(s[:bestAt]),
+		Op:     best,
+		Value:  strings.TrimSpace(s[bestAt+len(best):]),
+	}, nil
+}
+
+// Apply runs the query against t and returns the resulting table
+// Filters and sort keys may use columns which are not selected by Columns
+// A footer is kept as it is, with only the selected columns
+func (q Query) Apply(t Table) (Table, error) {
+	headers := t.TableHeaders()
+	rows := t.TableData()
+
+	filtered := [][]string{}
+	var filters []func([]string) bool
+	for _, f := range q.Filters {
+		match, err := f.matcher(headers)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, match)
+	}
+	for _, row := range rows {
+		row = padRow(row, len(headers))
+		keep := true
+		for _, match := range filters {
+			if !match(row) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			filtered = append(filtered, row)
+		}
+	}
+
+	if len(q.Sort) > 0 {
+		var indexes []int
+		for _, key := range q.Sort {
+			i, err := columnIndex(headers, key.Column)
+			if err != nil {
+				return nil, errors.WithMessage(err, "could not sort")
+			}
+			indexes = append(indexes, i)
+		}
+		sort.SliceStable(filtered, func(a, b int) bool {
+			for n, key := range q.Sort {
+				c := compareValues(filtered[a][indexes[n]], filtered[b][indexes[n]])
+				if c == 0 {
+					continue
+				}
+				if key.Desc {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+	}
+
+	footer := tableFooter(t)
+	if len(q.Columns) == 0 {
+		return RawTable{Headers: headers, Data: filtered, Footer: footer}, nil
+	}
+	var indexes []int
+	var projected []string
+	for _, c := range q.Columns {
+		i, err := columnIndex(headers, c)
+		if err != nil {
+			return nil, errors.WithMessage(err, "could not select columns")
+		}
+		indexes = append(indexes, i)
+		projected = append(projected, headers[i])
+	}
+	data := [][]string{}
+	for _, row := range filtered {
+		var out []string
+		for _, i := range indexes {
+			out = append(out, row[i])
+		}
+		data = append(data, out)
+	}
+	var projectedFooter []string
+	if footer != nil {
+		for _, i := range indexes {
+			projectedFooter = append(projectedFooter, footer[i])
+		}
+	}
+	return RawTable{Headers: projected, Data: data, Footer: projectedFooter}, nil
+}
+
+// matcher returns a function reporting whether a row passes the filter
+func (f Filter) matcher(headers []string) (func([]string) bool, error) {
+	i, err := columnIndex(headers, f.Column)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not filter")
+	}
+	switch f.Op {
+	case "=":
+		return func(row []string) bool { return compareValues(row[i], f.Value) == 0 }, nil
+	case "!=":
+		return func(row []string) bool { return compareValues(row[i], f.Value) != 0 }, nil
+	case "<":
+		return func(row []string) bool { return compareValues(row[i], f.Value) < 0 }, nil
+	case "<=":
+		return func(row []string) bool { return compareValues(row[i], f.Value) <= 0 }, nil
+	case ">":
+		return func(row []string) bool { return compareValues(row[i], f.Value) > 0 }, nil
+	case ">=":
+		return func(row []string) bool { return compareValues(row[i], f.Value) >= 0 }, nil
+	case "~":
+		value := strings.ToLower(f.Value)
+		return func(row []string) bool { return strings.Contains(strings.ToLower(row[i]), value) }, nil
+	}
+	return nil, errors.Errorf("unknown filter operator %q", f.Op)
+}
+
+// columnIndex finds a header case insensitively
+func columnIndex(headers []string, column string) (int, error) {
+	for i, h := range headers {
+		if strings.EqualFold(h, column) {
+			return i, nil
+		}
+	}
+	return -1, errors.Errorf("unknown column %q, expected one of %s", column, strings.Join(headers, ", "))
+}
+
+// compareValues compares two cells, as numbers when both parse as numbers and as strings otherwise
+// numbers come before everything else so columns mixing numbers and text still sort consistently
+func compareValues(a, b string) int {
+	x, errA := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	y, errB := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	switch {
+	case errA == nil && errB == nil:
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// QueryFlags binds a Query and an output format to command line flags
+// Call Query once the flag set has been parsed
+type QueryFlags struct {
+	Format  string
+	columns string
+	sort    string
+	filters filterFlag
+}
+
+// filterFlag collects repeated -filter flags
+type filterFlag []Filter
+
+// String implements flag.Value
+func (f *filterFlag) String() string {
+	var parts []string
+	for _, filter := range *f {
+		parts = append(parts, filter.Column+filter.Op+filter.Value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set implements flag.Value
+func (f *filterFlag) Set(s string) error {
+	filter, err := ParseFilter(s)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, filter)
+	return nil
+}
+
+// RegisterFlags adds -format, -columns, -sort and -filter to fs, pass flag.CommandLine for a program's own flags
+func RegisterFlags(fs *flag.FlagSet) *QueryFlags {
+	qf := &QueryFlags{}
+	fs.StringVar(&qf.Format, "format", "ascii", "output format, one of "+strings.Join(Formats(), ", "))
+	fs.StringVar(&qf.columns, "columns", "", "comma separated columns to show e.g. Name,State")
+	fs.StringVar(&qf.sort, "sort", "", "comma separated columns to sort by, prefix with - to sort descending e.g. -InstancesCount,Name")
+	fs.Var(&qf.filters, "filter", "keep rows matching an expression e.g. State=enabled, may be repeated (operators = != < <= > >= ~)")
+	return qf
+}
+
+// Query returns the query described by the parsed flags
+func (qf *QueryFlags) Query() Query {
+	return Query{Columns: ParseColumns(qf.columns), Sort: ParseSort(qf.sort), Filters: qf.filters}
+}
+
+// Render applies the flags' query to t and writes it to w in the flags' format
+func (qf *QueryFlags) Render(w io.Writer, t Table) error {
+	result, err := qf.Query().Apply(t)
+	if err != nil {
+		return err
+	}
+	return Render(w, result, qf.Format)
+}
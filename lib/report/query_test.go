@@ -0,0 +1,229 @@
+package report
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// instancesTable is a small table of instances shared by the query and aggregate tests
+var instancesTable = RawTable{Headers: []string{"Name", "State", "Count"}, Data: [][]string{
+	{"web", "running", "10"},
+	{"db", "stopped", "2"},
+	{"cache", "running", "9"},
+	{"queue", "Running", "2"},
+}}
+
+// rowsString joins a table's rows for easy comparison e.g. web:10,cache:9
+func rowsString(t Table) string {
+	var rows []string
+	for _, row := range t.TableData() {
+		rows = append(rows, strings.Join(row, ":"))
+	}
+	return strings.Join(rows, ",")
+}
+
+func TestParseColumns(t *testing.T) {
+	if got := ParseColumns(" Name, ,State,"); !reflect.DeepEqual(got, []string{"Name", "State"}) {
+		t.Errorf("ParseColumns() = %q, want [Name State]", got)
+	}
+	if got := ParseColumns(""); got != nil {
+		t.Errorf("ParseColumns(\"\") = %q, want nil", got)
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	want := []SortKey{{Column: "Count", Desc: true}, {Column: "Name"}, {Column: "State"}}
+	if got := ParseSort("-Count, Name,+State"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSort() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Filter
+		err  bool
+	}{
+		{in: "State=enabled", want: Filter{Column: "State", Op: "=", Value: "enabled"}},
+		{in: "Count >= 2", want: Filter{Column: "Count", Op: ">=", Value: "2"}},
+		{in: "Count<=2", want: Filter{Column: "Count", Op: "<=", Value: "2"}},
+		{in: "State!=stopped", want: Filter{Column: "State", Op: "!=", Value: "stopped"}},
+		{in: "Name~a=b", want: Filter{Column: "Name", Op: "~", Value: "a=b"}},
+		{in: "Name", err: true},
+		{in: "=web", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseFilter(tt.in)
+			if tt.err {
+				if err == nil {
+					t.Errorf("ParseFilter() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFilter() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   Query
+		headers string
+		rows    string
+		err     string
+	}{
+		{
+			name:    "empty query",
+			headers: "Name,State,Count",
+			rows:    "web:running:10,db:stopped:2,cache:running:9,queue:Running:2",
+		},
+		{
+			name:    "columns are case insensitive",
+			query:   Query{Columns: []string{"count", "NAME"}},
+			headers: "Count,Name",
+			rows:    "10:web,2:db,9:cache,2:queue",
+		},
+		{
+			name:    "numbers sort as numbers",
+			query:   Query{Columns: []string{"Name"}, Sort: []SortKey{{Column: "Count"}}},
+			headers: "Name",
+			rows:    "db,queue,cache,web",
+		},
+		{
+			name:    "descending with a tie break",
+			query:   Query{Sort: []SortKey{{Column: "Count", Desc: true}, {Column: "Name", Desc: true}}},
+			headers: "Name,State,Count",
+			rows:    "web:running:10,cache:running:9,queue:Running:2,db:stopped:2",
+		},
+		{
+			name:    "numeric filter",
+			query:   Query{Columns: []string{"Name"}, Filters: []Filter{{Column: "Count", Op: ">", Value: "9"}}},
+			headers: "Name",
+			rows:    "web",
+		},
+		{
+			name: "filters combine",
+			query: Query{Columns: []string{"Name"}, Filters: []Filter{
+				{Column: "State", Op: "~", Value: "RUN"},
+				{Column: "Count", Op: "<=", Value: "9"},
+			}},
+			headers: "Name",
+			rows:    "cache,queue",
+		},
+		{
+			name:    "no rows match",
+			query:   Query{Filters: []Filter{{Column: "State", Op: "=", Value: "pending"}}},
+			headers: "Name,State,Count",
+		},
+		{
+			name:  "unknown filter column",
+			query: Query{Filters: []Filter{{Column: "Zone", Op: "=", Value: "a"}}},
+			err:   "could not filter",
+		},
+		{
+			name:  "unknown operator",
+			query: Query{Filters: []Filter{{Column: "Name", Op: "^", Value: "a"}}},
+			err:   "unknown filter operator",
+		},
+		{
+			name:  "unknown sort column",
+			query: Query{Sort: []SortKey{{Column: "Zone"}}},
+			err:   "could not sort",
+		},
+		{
+			name:  "unknown column",
+			query: Query{Columns: []string{"Zone"}},
+			err:   "could not select columns",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.query.Apply(instancesTable)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Apply() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %s", err)
+			}
+			if headers := strings.Join(got.TableHeaders(), ","); headers != tt.headers {
+				t.Errorf("headers = %q, want %q", headers, tt.headers)
+			}
+			if rows := rowsString(got); rows != tt.rows {
+				t.Errorf("rows = %q, want %q", rows, tt.rows)
+			}
+		})
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "2", b: "10", want: -1},
+		{a: " 2.5", b: "2.50", want: 0},
+		{a: "-1", b: "-3", want: 1},
+		{a: "10", b: "9a", want: -1},
+		{a: "9a", b: "10", want: 1},
+		{a: "", b: "0", want: 1},
+		{a: "b", b: "a", want: 1},
+	}
+	for _, tt := range tests {
+		if got := compareValues(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareValues(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCompareValuesIsTransitive(t *testing.T) {
+	//with numbers compared as numbers and text as text 2 < 10, 10 < 1a and 1a < 2 made a cycle
+	values := []string{"10", "1a", "9b", "2", "", "abc", "-1.5", "1e3"}
+	for _, a := range values {
+		for _, b := range values {
+			for _, c := range values {
+				if compareValues(a, b) < 0 && compareValues(b, c) < 0 && compareValues(a, c) >= 0 {
+					t.Errorf("%q < %q and %q < %q but not %q < %q", a, b, b, c, a, c)
+				}
+			}
+		}
+	}
+}
+
+func TestQueryApplyMixedColumn(t *testing.T) {
+	table := RawTable{Headers: []string{"Value"}, Data: [][]string{{"9b"}, {"10"}, {"n/a"}, {"2"}, {"9a"}}}
+	got, err := Query{Sort: []SortKey{{Column: "Value"}}}.Apply(table)
+	if err != nil {
+		t.Fatalf("Apply() error = %s", err)
+	}
+	if rows := rowsString(got); rows != "2,10,9a,9b,n/a" {
+		t.Errorf("rows = %q, want numbers first and then text", rows)
+	}
+}
+
+func TestQueryApplyKeepsFooter(t *testing.T) {
+	table := RawTable{Headers: instancesTable.Headers, Data: instancesTable.Data, Footer: []string{"Total", "", "23"}}
+	got, err := Query{Columns: []string{"Count", "Name"}, Sort: []SortKey{{Column: "Name"}}}.Apply(table)
+	if err != nil {
+		t.Fatalf("Apply() error = %s", err)
+	}
+	if footer := strings.Join(tableFooter(got), ":"); footer != "23:Total" {
+		t.Errorf("footer = %q, want 23:Total", footer)
+	}
+	if got, _ := (Query{}).Apply(table); strings.Join(tableFooter(got), ":") != "Total::23" {
+		t.Errorf("footer = %q, want it unchanged", tableFooter(got))
+	}
+	if got, _ := (Query{Columns: []string{"Name"}}).Apply(instancesTable); tableFooter(got) != nil {
+		t.Errorf("footer = %q, want none for a table without one", tableFooter(got))
+	}
+}
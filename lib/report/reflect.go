@@ -0,0 +1,241 @@
+package report
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TimeFormat is the layout FromSlice uses for time.Time fields without a format option
+var TimeFormat = time.RFC3339
+
+// reflectTable is the Table built by FromSlice
+type reflectTable struct {
+	headers []string
+	data    [][]string
+}
+
+// TableHeaders implements Table
+func (t reflectTable) TableHeaders() []string {
+	return t.headers
+}
+
+// TableData implements Table
+func (t reflectTable) TableData() [][]string {
+	return t.data
+}
+
+// column is a single flattened struct field
+type column struct {
+	header string
+	index  []int
+	format string
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// FromSlice builds a Table from a slice of structs, or pointers to structs, with one column per exported field
+// Nested structs are flattened into columns named after the field path e.g. ElasticityParams.Bounds.MaxCount
+// The table struct tag renames or formats a column, and "-" skips the field
+//
+//	Name  string    `table:"Array Name"`
+//	Price float64   `table:"Price,format=%.2f"`
+//	Seen  time.Time `table:",format=2006-01-02"`
+//	Up    bool      `table:",format=yes/no"`
+//	Links rsLinks   `table:"-"`
+//
+// format is a layout for times, a true/false pair of words for bools and a fmt verb for everything else
+// Slices are joined with a comma, maps are written as sorted key=value pairs and nil values are empty
+func FromSlice(slice interface{}) (Table, error) {
+	v := reflect.ValueOf(slice)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, errors.Errorf("report.FromSlice needs a slice of structs, got %T", slice)
+	}
+	elem := v.Type().Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return nil, errors.Errorf("report.FromSlice needs a slice of structs, got %T", slice)
+	}
+	columns := structColumns(elem, nil, "", map[reflect.Type]bool{})
+
+	t := reflectTable{data: [][]string{}}
+	for _, c := range columns {
+		t.headers = append(t.headers, c.header)
+	}
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		if item.Kind() == reflect.Ptr {
+			if item.IsNil() {
+				t.data = append(t.data, make([]string, len(columns)))
+				continue
+			}
+			item = item.Elem()
+		}
+		var row []string
+		for _, c := range columns {
+			field, ok := fieldByIndex(item, c.index)
+			if !ok {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, formatValue(field, c.format))
+		}
+		t.data = append(t.data, row)
+	}
+	return t, nil
+}
+
+// structColumns lists the columns of a struct type, seen stops recursive types from being flattened forever
+func structColumns(t reflect.Type, index []int, prefix string, seen map[reflect.Type]bool) []column {
+	seen[t] = true
+	defer delete(seen, t)
+
+	var columns []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		//fields of an unexported embedded struct are promoted so only the struct itself is unexported
+		if f.PkgPath != "" && !(f.Anonymous && indirect(f.Type).Kind() == reflect.Struct) {
+			continue
+		}
+		name, format, skip := parseTableTag(f.Tag.Get("table"))
+		if skip {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		ft := indirect(f.Type)
+		if ft.Kind() == reflect.Struct && flattens(ft) && !seen[ft] {
+			nested := prefix
+			switch {
+			case name != "":
+				nested = prefix + name + "."
+			case !f.Anonymous:
+				nested = prefix + f.Name + "."
+			}
+			columns = append(columns, structColumns(ft, fieldIndex, nested, seen)...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		columns = append(columns, column{header: prefix + name, index: fieldIndex, format: format})
+	}
+	return columns
+}
+
+// indirect returns the type a pointer type points to, other types are returned as they are
+func indirect(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// flattens reports whether a struct type is split into columns rather than formatted as a single value
+func flattens(t reflect.Type) bool {
+	if t == timeType {
+		return false
+	}
+	return !t.Implements(stringerType) && !reflect.PtrTo(t).Implements(stringerType)
+}
+
+// parseTableTag splits a table struct tag into its header and format, everything after format= is the format
+func parseTableTag(tag string) (name string, format string, skip bool) {
+	if tag == "-" {
+		return "", "", true
+	}
+	parts := strings.SplitN(tag, ",", 2)
+	name = strings.TrimSpace(parts[0])
+	if len(parts) == 2 {
+		format = strings.TrimPrefix(strings.TrimSpace(parts[1]), "format=")
+	}
+	return name, format, false
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex which reports false instead of panicking on a nil pointer
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for n, i := range index {
+		if n > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// formatValue formats a single field value
+func formatValue(v reflect.Value, format string) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+		if format == "" {
+			format = TimeFormat
+		}
+		return t.Format(format)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if words := strings.SplitN(format, "/", 2); len(words) == 2 {
+			if v.Bool() {
+				return words[0]
+			}
+			return words[1]
+		}
+		return fmt.Sprint(v.Bool())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return ""
+		}
+		var items []string
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, formatValue(v.Index(i), format))
+		}
+		return strings.Join(items, ", ")
+	case reflect.Map:
+		if v.IsNil() {
+			return ""
+		}
+		var items []string
+		for _, key := range v.MapKeys() {
+			items = append(items, fmt.Sprintf("%v=%s", key.Interface(), formatValue(v.MapIndex(key), format)))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ", ")
+	}
+
+	if !v.CanInterface() {
+		return ""
+	}
+	if format != "" {
+		return fmt.Sprintf(format, v.Interface())
+	}
+	return fmt.Sprint(v.Interface())
+}
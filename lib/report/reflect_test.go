@@ -0,0 +1,156 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// level is formatted through its String method
+type level string
+
+func (l level) String() string {
+	return "level " + string(l)
+}
+
+// version is a Stringer struct so it is one column rather than flattened
+type version struct {
+	Major, Minor int
+}
+
+func (v version) String() string {
+	return fmt.Sprintf("v%d.%d", v.Major, v.Minor)
+}
+
+type reflectBounds struct {
+	Min, Max int
+}
+
+type reflectParams struct {
+	Bounds reflectBounds
+	Note   *string
+}
+
+type reflectBase struct {
+	ID int
+}
+
+type reflectRow struct {
+	reflectBase
+	Name    string    `table:"Array Name"`
+	Price   float64   `table:"Price,format=%.2f"`
+	Seen    time.Time `table:",format=2006-01-02"`
+	Created time.Time
+	Up      bool `table:",format=yes/no"`
+	Level   level
+	Version version
+	Params  *reflectParams
+	Zones   []string
+	Tags    map[string]string
+	Secret  string `table:"-"`
+	hidden  int
+}
+
+// reflectNode refers to itself so Next is a single column rather than flattened forever
+type reflectNode struct {
+	Name string
+	Next *reflectNode
+}
+
+func TestFromSlice(t *testing.T) {
+	note := "busy"
+	seen := time.Date(2020, 1, 2, 13, 4, 5, 0, time.UTC)
+	rows := []*reflectRow{
+		{
+			reflectBase: reflectBase{ID: 7}, Name: "web", Price: 1.5, Seen: seen, Created: seen, Up: true,
+			Level: "high", Version: version{Major: 1, Minor: 2}, Params: &reflectParams{Bounds: reflectBounds{Min: 1, Max: 3}, Note: &note},
+			Zones: []string{"a", "b"}, Tags: map[string]string{"team": "web", "env": "prod"}, Secret: "x", hidden: 1,
+		},
+		{Name: "db"},
+		nil,
+	}
+	got, err := FromSlice(rows)
+	if err != nil {
+		t.Fatalf("FromSlice() error = %s", err)
+	}
+	wantHeaders := "ID,Array Name,Price,Seen,Created,Up,Level,Version,Params.Bounds.Min,Params.Bounds.Max,Params.Note,Zones,Tags"
+	if headers := strings.Join(got.TableHeaders(), ","); headers != wantHeaders {
+		t.Errorf("headers = %q, want %q", headers, wantHeaders)
+	}
+	want := []string{
+		"7|web|1.50|2020-01-02|2020-01-02T13:04:05Z|yes|level high|v1.2|1|3|busy|a, b|env=prod, team=web",
+		//zero times, nil pointers and nil slices and maps are empty
+		"0|db|0.00|||no|level |v0.0|||||",
+		"||||||||||||",
+	}
+	data := got.TableData()
+	if len(data) != len(want) {
+		t.Fatalf("FromSlice() returned %d rows, want %d", len(data), len(want))
+	}
+	for i, row := range data {
+		if strings.Join(row, "|") != want[i] {
+			t.Errorf("row %d = %q, want %q", i, strings.Join(row, "|"), want[i])
+		}
+	}
+}
+
+func TestFromSliceTimeFormat(t *testing.T) {
+	defer func(format string) { TimeFormat = format }(TimeFormat)
+	TimeFormat = "02 Jan 06"
+	seen := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	got, err := FromSlice([]struct {
+		Seen  time.Time
+		Until *time.Time `table:"Until,format=2006"`
+	}{{Seen: seen, Until: &seen}})
+	if err != nil {
+		t.Fatalf("FromSlice() error = %s", err)
+	}
+	if row := strings.Join(got.TableData()[0], "|"); row != "02 Jan 20|2020" {
+		t.Errorf("row = %q, want 02 Jan 20|2020", row)
+	}
+}
+
+func TestFromSliceRecursiveType(t *testing.T) {
+	got, err := FromSlice(&[]reflectNode{{Name: "a"}})
+	if err != nil {
+		t.Fatalf("FromSlice() error = %s", err)
+	}
+	if headers := strings.Join(got.TableHeaders(), ","); headers != "Name,Next" {
+		t.Errorf("headers = %q, want Name,Next", headers)
+	}
+	if row := strings.Join(got.TableData()[0], "|"); row != "a|" {
+		t.Errorf("row = %q, want a|", row)
+	}
+}
+
+func TestFromSliceErrors(t *testing.T) {
+	for _, in := range []interface{}{reflectRow{}, []int{1}, nil} {
+		if _, err := FromSlice(in); err == nil {
+			t.Errorf("FromSlice(%T) did not fail", in)
+		}
+	}
+	got, err := FromSlice([]reflectBounds{})
+	if err != nil || len(got.TableData()) != 0 || strings.Join(got.TableHeaders(), ",") != "Min,Max" {
+		t.Errorf("FromSlice() of an empty slice = %v, %v, want headers and no rows", got, err)
+	}
+}
+
+func TestParseTableTag(t *testing.T) {
+	tests := []struct {
+		tag, name, format string
+		skip              bool
+	}{
+		{tag: "", name: "", format: ""},
+		{tag: "-", skip: true},
+		{tag: "Array Name", name: "Array Name"},
+		{tag: " Price , format=%.2f", name: "Price", format: "%.2f"},
+		{tag: ",format=a,b", name: "", format: "a,b"},
+	}
+	for _, tt := range tests {
+		name, format, skip := parseTableTag(tt.tag)
+		if name != tt.name || format != tt.format || skip != tt.skip {
+			t.Errorf("parseTableTag(%q) = %q, %q, %t, want %q, %q, %t", tt.tag, name, format, skip, tt.name, tt.format, tt.skip)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package report
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Renderer writes a Table to a writer in a particular format
+type Renderer interface {
+	Render(w io.Writer, t Table) error
+}
+
+// RendererFunc allows an ordinary function to be used as a Renderer
+type RendererFunc func(w io.Writer, t Table) error
+
+// Render calls f(w, t)
+func (f RendererFunc) Render(w io.Writer, t Table) error {
+	return f(w, t)
+}
+
+// CSVRenderer writes comma separated values with a header row, set Comma to use another separator
+type CSVRenderer struct {
+	Comma rune
+}
+
+// JSONRenderer writes an array of objects keyed by header, keys keep the table's column order
+type JSONRenderer struct {
+	Indent string
+}
+
+// MarkdownRenderer writes a GitHub flavoured markdown table
+type MarkdownRenderer struct{}
+
+// HTMLRenderer writes a standalone HTML document containing the table
+type HTMLRenderer struct {
+	Title string
+}
+
+// renderers holds the built in formats, xlsx is registered by importing the report/xlsx package so programs
+// which never write workbooks do not build in its dependencies
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]Renderer{
+		"ascii":       ASCIIRenderer{},
+		"table":       ASCIIRenderer{},
+		"record":      ASCIIRenderer{Layout: LayoutRecord},
+		"csv":         CSVRenderer{},
+		"tsv":         CSVRenderer{Comma: '\t'},
+		"json":        JSONRenderer{Indent: "  "},
+		"jsonl":       JSONLinesRenderer{},
+		"markdown":    MarkdownRenderer{},
+		"md":          MarkdownRenderer{},
+		"html":        HTMLRenderer{},
+		"tree":        TreeRenderer{},
+		"tree-indent": TreeRenderer{Style: TreeIndent},
+	}
+)
+
+// RegisterRenderer makes a renderer available to NewRenderer under name, replacing any renderer with that name
+func RegisterRenderer(name string, r Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	renderers[strings.ToLower(name)] = r
+}
+
+// NewRenderer returns the renderer registered for a format name such as csv, json or markdown
+func NewRenderer(format string) (Renderer, error) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	r, ok := renderers[strings.ToLower(strings.TrimSpace(format))]
+	if !ok {
+		return nil, errors.Errorf("unknown report format %q, expected one of %s", format, strings.Join(formats(), ", "))
+	}
+	return r, nil
+}
+
+// Formats returns the names of every registered format
+func Formats() []string {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	return formats()
+}
+
+func formats() []string {
+	var names []string
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render writes t to w in the named format
+func Render(w io.Writer, t Table, format string) error {
+	r, err := NewRenderer(format)
+	if err != nil {
+		return err
+	}
+	return r.Render(w, t)
+}
+
+// Render implements Renderer
+func (r CSVRenderer) Render(w io.Writer, t Table) error {
+	cw := csv.NewWriter(w)
+	if r.Comma != 0 {
+		cw.Comma = r.Comma
+	}
+	headers := t.TableHeaders()
+	if err := cw.Write(headers); err != nil {
+		return errors.Errorf("could not write csv header %s", err)
+	}
+	for _, row := range t.TableData() {
+		if err := cw.Write(padRow(row, len(headers))); err != nil {
+			return errors.Errorf("could not write csv row %s", err)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Render implements Renderer
+func (r JSONRenderer) Render(w io.Writer, t Table) error {
+	bw := bufio.NewWriter(w)
+	headers := t.TableHeaders()
+	rows := t.TableData()
+	newline, indent := "", ""
+	if r.Indent != "" {
+		newline, indent = "\n", r.Indent
+	}
+	bw.WriteString("[")
+	for n, row := range rows {
+		if n > 0 {
+			bw.WriteString(",")
+		}
+		bw.WriteString(newline + indent)
+		object, err := jsonObject(headers, row)
+		if err != nil {
+			return err
+		}
+		bw.Write(object)
+	}
+	if len(rows) > 0 {
+		bw.WriteString(newline)
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
+
+// jsonObject encodes a row as a JSON object keyed by header, keeping the column order
+func jsonObject(headers []string, row []string) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("{")
+	for i, value := range padRow(row, len(headers)) {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		key, err := json.Marshal(headers[i])
+		if err != nil {
+			return nil, errors.Errorf("could not encode json key %s", err)
+		}
+		val, err := json.Marshal(value)
+		if err != nil {
+			return nil, errors.Errorf("could not encode json value %s", err)
+		}
+		b.Write(key)
+		b.WriteString(":")
+		b.Write(val)
+	}
+	b.WriteString("}")
+	return []byte(b.String()), nil
+}
+
+// Render implements Renderer
+func (r MarkdownRenderer) Render(w io.Writer, t Table) error {
+	bw := bufio.NewWriter(w)
+	headers := t.TableHeaders()
+	writeMarkdownRow(bw, headers)
+	var rule []string
+	for range headers {
+		rule = append(rule, "---")
+	}
+	writeMarkdownRow(bw, rule)
+	for _, row := range t.TableData() {
+		writeMarkdownRow(bw, padRow(row, len(headers)))
+	}
+	return bw.Flush()
+}
+
+// writeMarkdownRow writes a single markdown table row, escaping pipes and flattening newlines
+func writeMarkdownRow(w io.Writer, cells []string) {
+	var escaped []string
+	for _, cell := range cells {
+		cell = strings.Replace(cell, "|", `\|`, -1)
+		cell = strings.Replace(cell, "\n", "<br>", -1)
+		escaped = append(escaped, cell)
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+}
+
+// Render implements Renderer
+func (r HTMLRenderer) Render(w io.Writer, t Table) error {
+	bw := bufio.NewWriter(w)
+	title := r.Title
+	if title == "" {
+		title = "Report"
+	}
+	writeHTMLHead(bw, title, "")
+	writeHTMLTable(bw, t)
+	writeHTMLFoot(bw)
+	return bw.Flush()
+}
+
+// writeHTMLHead starts a standalone HTML document with the table styling every HTML renderer shares
+// style is extra CSS for the renderer's own classes
+func writeHTMLHead(w io.Writer, title string, style string) {
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	io.WriteString(w, "<style>\ntable{border-collapse:collapse;font-family:sans-serif;font-size:14px}\n"+
+		"th,td{border:1px solid #ccc;padding:4px 8px;text-align:left}\nth{background:#f0f0f0}\n"+style+"</style>\n</head>\n<body>\n")
+}
+
+// writeHTMLFoot ends a document started with writeHTMLHead
+func writeHTMLFoot(w io.Writer) {
+	io.WriteString(w, "</body>\n</html>\n")
+}
+
+// writeHTMLTable writes just the table element, so other renderers can embed tables in their own documents
+func writeHTMLTable(w io.Writer, t Table) {
+	headers := t.TableHeaders()
+	io.WriteString(w, "<table>\n<thead>\n<tr>")
+	for _, h := range headers {
+		fmt.Fprintf(w, "<th>%s</th>", html.EscapeString(h))
+	}
+	io.WriteString(w, "</tr>\n</thead>\n<tbody>\n")
+	for _, row := range t.TableData() {
+		io.WriteString(w, "<tr>")
+		for _, cell := range padRow(row, len(headers)) {
+			fmt.Fprintf(w, "<td>%s</td>", html.EscapeString(cell))
+		}
+		io.WriteString(w, "</tr>\n")
+	}
+	io.WriteString(w, "</tbody>\n")
+	if footer := tableFooter(t); footer != nil {
+		io.WriteString(w, "<tfoot>\n<tr>")
+		for _, cell := range footer {
+			fmt.Fprintf(w, "<th>%s</th>", html.EscapeString(cell))
+		}
+		io.WriteString(w, "</tr>\n</tfoot>\n")
+	}
+	io.WriteString(w, "</table>\n")
+}
+
+// tableFooter returns the footer of a table which has one padded to the width of the headers, or nil
+func tableFooter(t Table) []string {
+	f, ok := t.(Footer)
+	if !ok {
+		return nil
+	}
+	footer := f.TableFooter()
+	if len(footer) == 0 {
+		return nil
+	}
+	return padRow(footer, len(t.TableHeaders()))
+}
+
+// padRow returns row with exactly width cells, missing cells are empty and extra cells are dropped
+func padRow(row []string, width int) []string {
+	if len(row) == width {
+		return row
+	}
+	padded := make([]string, width)
+	copy(padded, row)
+	return padded
+}
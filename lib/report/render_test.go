@@ -0,0 +1,141 @@
+package report
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// renderTable has the cells every text format has to escape and a short row which must be padded
+var renderTable = RawTable{
+	Headers: []string{"Name", "Note"},
+	Data: [][]string{
+		{"web", "a|b"},
+		{"<db>", "x\ny"},
+		{"short"},
+	},
+	Footer: []string{"Total", "3"},
+}
+
+func TestRenderFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "csv", want: "Name,Note\nweb,a|b\n<db>,\"x\ny\"\nshort,\n"},
+		{format: "tsv", want: "Name\tNote\nweb\ta|b\n<db>\t\"x\ny\"\nshort\t\n"},
+		{format: "json", want: "[\n" +
+			`  {"Name":"web","Note":"a|b"},` + "\n" +
+			`  {"Name":"\u003cdb\u003e","Note":"x\ny"},` + "\n" +
+			`  {"Name":"short","Note":""}` + "\n" +
+			"]\n"},
+		{format: "jsonl", want: `{"Name":"web","Note":"a|b"}` + "\n" +
+			`{"Name":"\u003cdb\u003e","Note":"x\ny"}` + "\n" +
+			`{"Name":"short","Note":""}` + "\n"},
+		{format: "markdown", want: "| Name | Note |\n| --- | --- |\n| web | a\\|b |\n| <db> | x<br>y |\n| short |  |\n"},
+		{format: "html", want: "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Report</title>\n" +
+			"<style>\ntable{border-collapse:collapse;font-family:sans-serif;font-size:14px}\n" +
+			"th,td{border:1px solid #ccc;padding:4px 8px;text-align:left}\nth{background:#f0f0f0}\n</style>\n</head>\n<body>\n" +
+			"<table>\n<thead>\n<tr><th>Name</th><th>Note</th></tr>\n</thead>\n<tbody>\n" +
+			"<tr><td>web</td><td>a|b</td></tr>\n" +
+			"<tr><td>&lt;db&gt;</td><td>x\ny</td></tr>\n" +
+			"<tr><td>short</td><td></td></tr>\n" +
+			"</tbody>\n<tfoot>\n<tr><th>Total</th><th>3</th></tr>\n</tfoot>\n</table>\n" +
+			"</body>\n</html>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Render(&buf, renderTable, tt.format); err != nil {
+				t.Fatalf("Render() error = %s", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Render() =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderEmptyTable(t *testing.T) {
+	empty := RawTable{Headers: []string{"Name"}}
+	tests := []struct {
+		renderer Renderer
+		want     string
+	}{
+		{renderer: JSONRenderer{Indent: "  "}, want: "[]\n"},
+		{renderer: JSONRenderer{}, want: "[]\n"},
+		{renderer: CSVRenderer{}, want: "Name\n"},
+		{renderer: MarkdownRenderer{}, want: "| Name |\n| --- |\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := tt.renderer.Render(&buf, empty); err != nil {
+			t.Fatalf("%T.Render() error = %s", tt.renderer, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%T.Render() = %q, want %q", tt.renderer, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestJSONRendererCompact(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (JSONRenderer{}).Render(&buf, RawTable{Headers: []string{"B", "A"}, Data: [][]string{{"1", "2"}, {"3", "4"}}}); err != nil {
+		t.Fatalf("Render() error = %s", err)
+	}
+	//keys keep the column order rather than being sorted
+	if want := `[{"B":"1","A":"2"},{"B":"3","A":"4"}]` + "\n"; buf.String() != want {
+		t.Errorf("Render() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestHTMLRendererTitle(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (HTMLRenderer{Title: "Arrays & <Instances>"}).Render(&buf, RawTable{Headers: []string{"<b>"}}); err != nil {
+		t.Fatalf("Render() error = %s", err)
+	}
+	got := buf.String()
+	if !strings.Contains(got, "<title>Arrays &amp; &lt;Instances&gt;</title>") {
+		t.Errorf("Render() title was not escaped\n%s", got)
+	}
+	if !strings.Contains(got, "<th>&lt;b&gt;</th>") || strings.Contains(got, "<tfoot>") {
+		t.Errorf("Render() header was not escaped or a footer was written without one\n%s", got)
+	}
+}
+
+func TestRendererRegistry(t *testing.T) {
+	for _, format := range []string{"CSV", " json ", "md", "tree-indent"} {
+		if _, err := NewRenderer(format); err != nil {
+			t.Errorf("NewRenderer(%q) error = %s", format, err)
+		}
+	}
+	_, err := NewRenderer("yaml")
+	if err == nil || !strings.Contains(err.Error(), `unknown report format "yaml"`) || !strings.Contains(err.Error(), "csv, html, json") {
+		t.Errorf("NewRenderer(yaml) error = %v, want the known formats listed", err)
+	}
+	if err := Render(io.Discard, renderTable, "yaml"); err == nil {
+		t.Error("Render() with an unknown format did not fail")
+	}
+
+	RegisterRenderer("Count", RendererFunc(func(w io.Writer, t Table) error {
+		_, err := io.WriteString(w, strings.Repeat("x", len(t.TableData())))
+		return err
+	}))
+	defer func() {
+		renderersMu.Lock()
+		delete(renderers, "count")
+		renderersMu.Unlock()
+	}()
+	var buf bytes.Buffer
+	if err := Render(&buf, renderTable, "COUNT"); err != nil || buf.String() != "xxx" {
+		t.Errorf("Render() with a registered format = %q, %v, want xxx", buf.String(), err)
+	}
+	found := false
+	for _, format := range Formats() {
+		found = found || format == "count"
+	}
+	if !found {
+		t.Errorf("Formats() = %q, want count registered", Formats())
+	}
+}
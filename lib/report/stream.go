@@ -0,0 +1,245 @@
+package report
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Rows iterates over the rows of a Stream in the manner of sql.Rows
+// Call Next before every Row, check Err once Next returns false and always Close the rows
+type Rows interface {
+	Next() bool
+	Row() []string
+	Err() error
+	Close() error
+}
+
+// Stream is a table whose rows are produced one at a time rather than held in memory
+// Every call to Rows starts a fresh pass over the rows
+type Stream interface {
+	TableHeaders() []string
+	Rows() Rows
+}
+
+// StreamRenderer is implemented by renderers which write rows as they arrive
+type StreamRenderer interface {
+	RenderStream(w io.Writer, s Stream) error
+}
+
+// JSONLinesRenderer writes one JSON object per row keyed by header, with no surrounding array
+type JSONLinesRenderer struct{}
+
+// streamSampleRows is how many rows the ASCII renderer reads before fixing its column widths
+const streamSampleRows = 50
+
+// errStreamClosed is returned to a producer's emit function once the rows have been closed
+var errStreamClosed = errors.New("report stream closed")
+
+// funcStream is the Stream built by NewStream
+type funcStream struct {
+	headers []string
+	produce func(emit func(row []string) error) error
+}
+
+// NewStream builds a Stream from a producer which calls emit once per row, e.g. once per instance as pages arrive
+// The producer runs in its own goroutine each time Rows is called. emit returns an error when the reader has closed
+// the rows early and the producer should stop and return. An error returned by the producer is reported by Rows.Err
+func NewStream(headers []string, produce func(emit func(row []string) error) error) Stream {
+	return funcStream{headers: headers, produce: produce}
+}
+
+// TableHeaders implements Stream
+func (s funcStream) TableHeaders() []string {
+	return s.headers
+}
+
+// Rows implements Stream
+func (s funcStream) Rows() Rows {
+	r := &chanRows{rows: make(chan []string), done: make(chan struct{})}
+	go func() {
+		defer close(r.rows)
+		err := s.produce(func(row []string) error {
+			select {
+			case r.rows <- row:
+				return nil
+			case <-r.done:
+				return errStreamClosed
+			}
+		})
+		if err != nil && err != errStreamClosed {
+			r.mu.Lock()
+			r.err = err
+			r.mu.Unlock()
+		}
+	}()
+	return r
+}
+
+// chanRows reads rows sent by a producer goroutine
+type chanRows struct {
+	rows    chan []string
+	done    chan struct{}
+	current []string
+	closeMu sync.Once
+	mu      sync.Mutex
+	err     error
+}
+
+// Next implements Rows
+func (r *chanRows) Next() bool {
+	row, ok := <-r.rows
+	r.current = row
+	return ok
+}
+
+// Row implements Rows
+func (r *chanRows) Row() []string {
+	return r.current
+}
+
+// Err implements Rows
+func (r *chanRows) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close implements Rows, it stops the producer and waits for it to return
+func (r *chanRows) Close() error {
+	r.closeMu.Do(func() { close(r.done) })
+	for range r.rows {
+	}
+	return nil
+}
+
+// tableStream is the Stream built by StreamTable
+type tableStream struct {
+	t Table
+}
+
+// StreamTable adapts a Table to a Stream, so any table can be passed to RenderStream
+func StreamTable(t Table) Stream {
+	return tableStream{t: t}
+}
+
+// TableHeaders implements Stream
+func (s tableStream) TableHeaders() []string {
+	return s.t.TableHeaders()
+}
+
+// Rows implements Stream
+func (s tableStream) Rows() Rows {
+	return &sliceRows{data: s.t.TableData(), n: -1}
+}
+
+// sliceRows iterates over rows already in memory
+type sliceRows struct {
+	data [][]string
+	n    int
+}
+
+// Next implements Rows
+func (r *sliceRows) Next() bool {
+	r.n++
+	return r.n < len(r.data)
+}
+
+// Row implements Rows
+func (r *sliceRows) Row() []string {
+	return r.data[r.n]
+}
+
+// Err implements Rows
+func (r *sliceRows) Err() error {
+	return nil
+}
+
+// Close implements Rows
+func (r *sliceRows) Close() error {
+	return nil
+}
+
+// Collect reads every row of a stream into a Table
+func Collect(s Stream) (Table, error) {
+	rows := s.Rows()
+	defer rows.Close()
+	t := RawTable{Headers: s.TableHeaders(), Data: [][]string{}}
+	for rows.Next() {
+		t.Data = append(t.Data, rows.Row())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithMessage(err, "could not collect stream")
+	}
+	return t, nil
+}
+
+// RenderStream writes s to w in the named format, rows are written as they arrive when the format supports it
+// and collected first when it does not
+func RenderStream(w io.Writer, s Stream, format string) error {
+	r, err := NewRenderer(format)
+	if err != nil {
+		return err
+	}
+	if sr, ok := r.(StreamRenderer); ok {
+		return sr.RenderStream(w, s)
+	}
+	t, err := Collect(s)
+	if err != nil {
+		return err
+	}
+	return r.Render(w, t)
+}
+
+// RenderStream implements StreamRenderer, every row is flushed as soon as it is written
+func (r CSVRenderer) RenderStream(w io.Writer, s Stream) error {
+	cw := csv.NewWriter(w)
+	if r.Comma != 0 {
+		cw.Comma = r.Comma
+	}
+	headers := s.TableHeaders()
+	if err := cw.Write(headers); err != nil {
+		return errors.Errorf("could not write csv header %s", err)
+	}
+	cw.Flush()
+	rows := s.Rows()
+	defer rows.Close()
+	for rows.Next() {
+		if err := cw.Write(padRow(rows.Row(), len(headers))); err != nil {
+			return errors.Errorf("could not write csv row %s", err)
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Render implements Renderer
+func (r JSONLinesRenderer) Render(w io.Writer, t Table) error {
+	return r.RenderStream(w, StreamTable(t))
+}
+
+// RenderStream implements StreamRenderer
+func (r JSONLinesRenderer) RenderStream(w io.Writer, s Stream) error {
+	headers := s.TableHeaders()
+	rows := s.Rows()
+	defer rows.Close()
+	bw := bufio.NewWriter(w)
+	for rows.Next() {
+		object, err := jsonObject(headers, rows.Row())
+		if err != nil {
+			return err
+		}
+		bw.Write(object)
+		bw.WriteString("\n")
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,123 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingWriter fails every write after the first n
+type failingWriter struct {
+	n   int
+	buf bytes.Buffer
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New("disk full")
+	}
+	w.n--
+	return w.buf.Write(p)
+}
+
+// lockedBuffer is a bytes.Buffer safe to read while a renderer writes to it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestASCIIRenderStream(t *testing.T) {
+	table := RawTable{Headers: []string{"name", "count"}, Data: [][]string{{"web", "2"}, {"database", "10"}}}
+	var want, got bytes.Buffer
+	if err := (ASCIIRenderer{Width: -1}).Render(&want, table); err != nil {
+		t.Fatalf("Render() error = %s", err)
+	}
+	if err := (ASCIIRenderer{Width: -1}).RenderStream(&got, StreamTable(table)); err != nil {
+		t.Fatalf("RenderStream() error = %s", err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("RenderStream() =\n%s\nwant\n%s", got.String(), want.String())
+	}
+}
+
+func TestASCIIRenderStreamSampleRows(t *testing.T) {
+	table := RawTable{Headers: []string{"name"}, Data: [][]string{{"web"}, {"database"}}}
+	var out bytes.Buffer
+	err := (ASCIIRenderer{Width: -1, SampleRows: 1}).RenderStream(&out, StreamTable(table))
+	if err != nil {
+		t.Fatalf("RenderStream() error = %s", err)
+	}
+	//the column is sized from the first row only, so the second is cut short
+	if !strings.Contains(out.String(), "| NAME |") || strings.Contains(out.String(), "database") {
+		t.Errorf("RenderStream() =\n%s\nwant columns sized from the first row", out.String())
+	}
+}
+
+func TestASCIIRenderStreamSampleTimeout(t *testing.T) {
+	release := make(chan struct{})
+	s := NewStream([]string{"name"}, func(emit func(row []string) error) error {
+		if err := emit([]string{"web"}); err != nil {
+			return err
+		}
+		<-release
+		return emit([]string{"db"})
+	})
+	out := &lockedBuffer{}
+	done := make(chan error)
+	go func() {
+		done <- ASCIIRenderer{Width: -1, SampleTimeout: 10 * time.Millisecond}.RenderStream(out, s)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "web") {
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("first row was not drawn while the stream was still open, got\n%s", out.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("RenderStream() error = %s", err)
+	}
+	if !strings.Contains(out.String(), "db") {
+		t.Errorf("RenderStream() =\n%s\nwant the row sent after the timeout", out.String())
+	}
+}
+
+func TestASCIIRenderStreamErrors(t *testing.T) {
+	table := RawTable{Headers: []string{"name"}, Data: [][]string{{"web"}}}
+	//border, header, border and the row all succeed and the closing border fails
+	w := &failingWriter{n: 4}
+	err := (ASCIIRenderer{Width: -1}).RenderStream(w, StreamTable(table))
+	if err == nil || err.Error() != "disk full" {
+		t.Errorf("RenderStream() error = %v, want the closing border error", err)
+	}
+
+	s := NewStream([]string{"name"}, func(emit func(row []string) error) error {
+		return errors.New("listing failed")
+	})
+	var out bytes.Buffer
+	err = (ASCIIRenderer{Width: -1}).RenderStream(&out, s)
+	if err == nil || err.Error() != "listing failed" {
+		t.Errorf("RenderStream() error = %v, want the stream error", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("RenderStream() wrote %q before failing", out.String())
+	}
+}
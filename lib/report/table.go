@@ -1,7 +1,6 @@
 package report
 
 import (
-	"github.com/olekukonko/tablewriter"
 	"os"
 )
 
@@ -14,17 +13,32 @@ type Table interface {
 //type TableData [][]string
 
 func OutputTable(t Table) {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetAutoWrapText(false)
-	table.SetHeader(t.TableHeaders())
-	table.AppendBulk(t.TableData())
-	table.Render()
+	ASCIIRenderer{}.Render(os.Stdout, t)
 }
 
 func DrawTable(headers []string, data [][]string) {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetAutoWrapText(false)
-	table.SetHeader(headers)
-	table.AppendBulk(data)
-	table.Render()
+	OutputTable(RawTable{Headers: headers, Data: data})
+}
+
+// RawTable is a Table built from headers and rows that are already strings
+// Footer is an optional totals row
+type RawTable struct {
+	Headers []string
+	Data    [][]string
+	Footer  []string
+}
+
+// TableHeaders implements Table
+func (t RawTable) TableHeaders() []string {
+	return t.Headers
+}
+
+// TableData implements Table
+func (t RawTable) TableData() [][]string {
+	return t.Data
+}
+
+// TableFooter implements Footer
+func (t RawTable) TableFooter() []string {
+	return t.Footer
 }
@@ -0,0 +1,308 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Template is a custom report layout parsed from text/template or html/template files
+// Files ending .html or .htm are parsed with html/template so their output is escaped, everything else with text/template
+type Template struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// TemplateRenderer renders a table through a template, the table is the template's data
+type TemplateRenderer struct {
+	Template *Template
+	Name     string
+}
+
+// tagger is implemented by tag lists such as rightscale's instance and array tags
+type tagger interface {
+	TagValue(name string) string
+}
+
+// ParseTemplateFiles parses layouts from files on disk, the first file is the template Execute runs
+func ParseTemplateFiles(paths ...string) (*Template, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("could not parse templates, no files given")
+	}
+	return parseTemplates(paths[0], func(name string) ([]byte, error) {
+		return os.ReadFile(name)
+	}, paths)
+}
+
+// ParseTemplateFS parses the layouts matching patterns from fsys, e.g. an embed.FS
+// the first file matched is the template Execute runs
+func ParseTemplateFS(fsys fs.FS, patterns ...string) (*Template, error) {
+	var names []string
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, errors.Errorf("invalid template pattern %s %s", pattern, err)
+		}
+		names = append(names, matches...)
+	}
+	if len(names) == 0 {
+		return nil, errors.Errorf("could not parse templates, nothing matches %s", strings.Join(patterns, ", "))
+	}
+	return parseTemplates(names[0], func(name string) ([]byte, error) {
+		return fs.ReadFile(fsys, name)
+	}, names)
+}
+
+// parseTemplates reads and parses every file into one template set named after the first file
+func parseTemplates(first string, read func(name string) ([]byte, error), names []string) (*Template, error) {
+	t := &Template{}
+	ext := strings.ToLower(filepath.Ext(first))
+	if ext == ".html" || ext == ".htm" {
+		t.html = htmltemplate.New(filepath.Base(first)).Funcs(htmltemplate.FuncMap(TemplateFuncs(true)))
+	} else {
+		t.text = texttemplate.New(filepath.Base(first)).Funcs(TemplateFuncs(false))
+	}
+	for _, name := range names {
+		data, err := read(name)
+		if err != nil {
+			return nil, errors.Errorf("could not read template %s %s", name, err)
+		}
+		switch {
+		case t.html != nil && name == first:
+			_, err = t.html.Parse(string(data))
+		case t.html != nil:
+			_, err = t.html.New(filepath.Base(name)).Parse(string(data))
+		case name == first:
+			_, err = t.text.Parse(string(data))
+		default:
+			_, err = t.text.New(filepath.Base(name)).Parse(string(data))
+		}
+		if err != nil {
+			return nil, errors.Errorf("could not parse template %s %s", name, err)
+		}
+	}
+	return t, nil
+}
+
+// Execute runs the first template parsed with data, which may be a Table or any inventory struct
+func (t *Template) Execute(w io.Writer, data interface{}) error {
+	if t.html != nil {
+		return t.html.Execute(w, data)
+	}
+	return t.text.Execute(w, data)
+}
+
+// ExecuteTemplate runs the named template, a file's base name or a template it defines
+func (t *Template) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	if t.html != nil {
+		return t.html.ExecuteTemplate(w, name, data)
+	}
+	return t.text.ExecuteTemplate(w, name, data)
+}
+
+// Render implements Renderer
+func (r TemplateRenderer) Render(w io.Writer, t Table) error {
+	if r.Template == nil {
+		return errors.New("could not render template, no template given")
+	}
+	if r.Name != "" {
+		return r.Template.ExecuteTemplate(w, r.Name, t)
+	}
+	return r.Template.Execute(w, t)
+}
+
+// TemplateFuncs returns the helpers available to every report template, html selects the html/template versions
+//
+//	tag .InstanceTags "role"             the value of a tag, from anything with a TagValue method or a map
+//	duration .Elapsed                    a time.Duration as e.g. 3d 4h
+//	since .CreatedAt                     the time since a time.Time or a date string, as duration does
+//	plural (len .Instances) "instance"   1 instance, 3 instances, a third argument gives an irregular plural
+//	table .Instances "markdown"          a Table or slice of structs drawn in a format, ascii by default
+//	headers .Table / rows .Table         the headers and rows of a Table or slice of structs
+//	join .PrivateIPAddresses ", "        strings.Join
+func TemplateFuncs(html bool) map[string]interface{} {
+	funcs := map[string]interface{}{
+		"tag":      tagValue,
+		"duration": HumanizeDuration,
+		"since":    since,
+		"plural":   pluralize,
+		"headers":  templateHeaders,
+		"rows":     templateRows,
+		"join":     strings.Join,
+		"table":    embedTable,
+	}
+	if html {
+		funcs["table"] = embedHTMLTable
+	}
+	return funcs
+}
+
+// tagValue looks a tag up in anything with a TagValue method or in a map of strings
+func tagValue(tags interface{}, name string) (string, error) {
+	switch t := tags.(type) {
+	case tagger:
+		return t.TagValue(name), nil
+	case map[string]string:
+		return t[name], nil
+	case nil:
+		return "", nil
+	}
+	return "", errors.Errorf("tag: %T has no tags", tags)
+}
+
+// HumanizeDuration formats a duration with its two largest units e.g. 3d 4h, 5m 10s
+// it is the template duration helper and is used for ages in tables so both read the same
+func HumanizeDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	units := []struct {
+		size time.Duration
+		name string
+	}{
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+		{time.Second, "s"},
+	}
+	var parts []string
+	for _, u := range units {
+		if d >= u.size {
+			parts = append(parts, fmt.Sprintf("%d%s", d/u.size, u.name))
+			d %= u.size
+		}
+		if len(parts) == 2 {
+			break
+		}
+	}
+	if len(parts) == 0 {
+		return "0s"
+	}
+	return strings.Join(parts, " ")
+}
+
+// DateLayouts are the layouts a date string is tried against, by since and by the xlsx package for typed date cells
+// the Rightscale created at format is included so instance listings get real dates
+var DateLayouts = []string{
+	time.RFC3339,
+	"2006/01/02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// since humanizes the time since a time.Time or a date string in one of DateLayouts
+func since(t interface{}) (string, error) {
+	switch v := t.(type) {
+	case time.Time:
+		return HumanizeDuration(time.Since(v)), nil
+	case *time.Time:
+		if v == nil {
+			return "", nil
+		}
+		return HumanizeDuration(time.Since(*v)), nil
+	case string:
+		for _, layout := range DateLayouts {
+			if parsed, err := time.Parse(layout, v); err == nil {
+				return HumanizeDuration(time.Since(parsed)), nil
+			}
+		}
+		return "", errors.Errorf("since: could not parse date %q", v)
+	}
+	return "", errors.Errorf("since: %T is not a time", t)
+}
+
+// pluralize writes a count followed by the singular or plural form of a word
+func pluralize(count interface{}, singular string, plural ...string) (string, error) {
+	n := reflect.ValueOf(count)
+	var one bool
+	switch n.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		one = n.Int() == 1
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		one = n.Uint() == 1
+	case reflect.Float32, reflect.Float64:
+		one = n.Float() == 1
+	default:
+		return "", errors.Errorf("plural: %T is not a number", count)
+	}
+	word := singular
+	if !one {
+		switch {
+		case len(plural) > 0:
+			word = plural[0]
+		case strings.HasSuffix(singular, "s"), strings.HasSuffix(singular, "x"), strings.HasSuffix(singular, "ch"), strings.HasSuffix(singular, "sh"):
+			word = singular + "es"
+		default:
+			word = singular + "s"
+		}
+	}
+	return fmt.Sprintf("%v %s", count, word), nil
+}
+
+// templateTable turns a template argument into a Table, slices of structs go through FromSlice
+func templateTable(v interface{}) (Table, error) {
+	if t, ok := v.(Table); ok {
+		return t, nil
+	}
+	return FromSlice(v)
+}
+
+// templateHeaders returns the headers of a Table or slice of structs
+func templateHeaders(v interface{}) ([]string, error) {
+	t, err := templateTable(v)
+	if err != nil {
+		return nil, err
+	}
+	return t.TableHeaders(), nil
+}
+
+// templateRows returns the rows of a Table or slice of structs
+func templateRows(v interface{}) ([][]string, error) {
+	t, err := templateTable(v)
+	if err != nil {
+		return nil, err
+	}
+	return t.TableData(), nil
+}
+
+// embedTable renders a Table or slice of structs to a string for text templates
+func embedTable(v interface{}, format ...string) (string, error) {
+	t, err := templateTable(v)
+	if err != nil {
+		return "", err
+	}
+	name := "ascii"
+	if len(format) > 0 {
+		name = format[0]
+	}
+	r, err := NewRenderer(name)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := r.Render(&buf, t); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// embedHTMLTable renders a Table or slice of structs as an HTML table element for html templates
+func embedHTMLTable(v interface{}) (htmltemplate.HTML, error) {
+	t, err := templateTable(v)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	writeHTMLTable(&buf, t)
+	return htmltemplate.HTML(buf.String()), nil
+}
@@ -0,0 +1,215 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// roleTags has a TagValue method like rightscale's tag lists
+type roleTags map[string]string
+
+func (r roleTags) TagValue(name string) string {
+	return r[name]
+}
+
+// execute runs a parsed template with data and returns its output
+func execute(t *testing.T, tmpl *Template, data interface{}) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		t.Fatalf("Execute() error = %s", err)
+	}
+	return buf.String()
+}
+
+func TestParseTemplateFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"report.txt":  {Data: []byte(`{{range .}}{{template "row" .}}{{end}}`)},
+		"row.txt":     {Data: []byte(`{{define "row"}}<{{.}}>{{end}}`)},
+		"report.html": {Data: []byte(`{{range .}}{{template "row.html" .}}{{end}}`)},
+		"row.html":    {Data: []byte(`<b>{{.}}</b>`)},
+	}
+	data := []string{"web", "a&b"}
+
+	text, err := ParseTemplateFS(fsys, "report.txt", "row.txt")
+	if err != nil {
+		t.Fatalf("ParseTemplateFS() error = %s", err)
+	}
+	if got := execute(t, text, data); got != "<web><a&b>" {
+		t.Errorf("text template = %q, want unescaped output", got)
+	}
+
+	html, err := ParseTemplateFS(fsys, "report.html", "row.html")
+	if err != nil {
+		t.Fatalf("ParseTemplateFS() error = %s", err)
+	}
+	if got := execute(t, html, data); got != "<b>web</b><b>a&amp;b</b>" {
+		t.Errorf("html template = %q, want escaped output", got)
+	}
+	var buf bytes.Buffer
+	if err := html.ExecuteTemplate(&buf, "row.html", "<i>"); err != nil || buf.String() != "<b>&lt;i&gt;</b>" {
+		t.Errorf("ExecuteTemplate(row.html) = %q, %v", buf.String(), err)
+	}
+
+	if _, err := ParseTemplateFS(fsys, "*.md"); err == nil {
+		t.Error("ParseTemplateFS() with no matches did not fail")
+	}
+	if _, err := ParseTemplateFS(fstest.MapFS{"bad.txt": {Data: []byte("{{end}}")}}, "bad.txt"); err == nil {
+		t.Error("ParseTemplateFS() of an invalid template did not fail")
+	}
+}
+
+func TestParseTemplateFiles(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.tmpl")
+	part := filepath.Join(dir, "part.tmpl")
+	if err := os.WriteFile(main, []byte(`{{.}} {{template "part" .}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(part, []byte(`{{define "part"}}[{{.}}]{{end}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := ParseTemplateFiles(main, part)
+	if err != nil {
+		t.Fatalf("ParseTemplateFiles() error = %s", err)
+	}
+	if got := execute(t, tmpl, "x"); got != "x [x]" {
+		t.Errorf("Execute() = %q, want x [x]", got)
+	}
+	if _, err := ParseTemplateFiles(); err == nil {
+		t.Error("ParseTemplateFiles() with no files did not fail")
+	}
+	if _, err := ParseTemplateFiles(filepath.Join(dir, "missing.tmpl")); err == nil {
+		t.Error("ParseTemplateFiles() of a missing file did not fail")
+	}
+}
+
+func TestTagValue(t *testing.T) {
+	tests := []struct {
+		name string
+		tags interface{}
+		want string
+		err  bool
+	}{
+		{name: "tagger", tags: roleTags{"role": "web"}, want: "web"},
+		{name: "map", tags: map[string]string{"role": "db"}, want: "db"},
+		{name: "missing", tags: map[string]string{}, want: ""},
+		{name: "nil", tags: nil, want: ""},
+		{name: "not tags", tags: 3, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tagValue(tt.tags, "role")
+			if (err != nil) != tt.err || got != tt.want {
+				t.Errorf("tagValue() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHumanizeDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 0, want: "0s"},
+		{d: 45 * time.Second, want: "45s"},
+		{d: 5*time.Minute + 10*time.Second + time.Millisecond, want: "5m 10s"},
+		{d: 76 * time.Hour, want: "3d 4h"},
+		{d: 72*time.Hour + 30*time.Minute, want: "3d 30m"},
+		{d: -2 * time.Hour, want: "2h"},
+	}
+	for _, tt := range tests {
+		if got := HumanizeDuration(tt.d); got != tt.want {
+			t.Errorf("HumanizeDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestSince(t *testing.T) {
+	threeDays := time.Now().Add(-72 * time.Hour)
+	tests := []struct {
+		name string
+		t    interface{}
+		want string
+		err  bool
+	}{
+		{name: "time", t: threeDays, want: "3d"},
+		{name: "pointer", t: &threeDays, want: "3d"},
+		{name: "nil pointer", t: (*time.Time)(nil), want: ""},
+		{name: "rightscale date", t: threeDays.UTC().Format("2006/01/02 15:04:05 -0700"), want: "3d"},
+		{name: "bad date", t: "yesterday", err: true},
+		{name: "not a time", t: 3, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := since(tt.t)
+			if (err != nil) != tt.err || got != tt.want {
+				t.Errorf("since() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		count    interface{}
+		singular string
+		plural   []string
+		want     string
+	}{
+		{count: 1, singular: "instance", want: "1 instance"},
+		{count: 3, singular: "instance", want: "3 instances"},
+		{count: 0, singular: "instance", want: "0 instances"},
+		{count: uint(2), singular: "box", want: "2 boxes"},
+		{count: 2, singular: "bus", want: "2 buses"},
+		{count: 2, singular: "match", want: "2 matches"},
+		{count: 2, singular: "dish", want: "2 dishes"},
+		{count: 1.0, singular: "day", want: "1 day"},
+		{count: 2, singular: "person", plural: []string{"people"}, want: "2 people"},
+		{count: 1, singular: "person", plural: []string{"people"}, want: "1 person"},
+	}
+	for _, tt := range tests {
+		if got, err := pluralize(tt.count, tt.singular, tt.plural...); err != nil || got != tt.want {
+			t.Errorf("pluralize(%v, %s) = %q, %v, want %q", tt.count, tt.singular, got, err, tt.want)
+		}
+	}
+	if _, err := pluralize("3", "instance"); err == nil {
+		t.Error("pluralize() of a string did not fail")
+	}
+}
+
+func TestTemplateTables(t *testing.T) {
+	table := RawTable{Headers: []string{"Name"}, Data: [][]string{{"<script>"}}}
+	fsys := fstest.MapFS{
+		"report.txt":  {Data: []byte(`{{table . "csv"}}{{table .}}`)},
+		"report.html": {Data: []byte(`{{table .}}`)},
+	}
+
+	text, err := ParseTemplateFS(fsys, "report.txt")
+	if err != nil {
+		t.Fatalf("ParseTemplateFS() error = %s", err)
+	}
+	got := execute(t, text, table)
+	if !strings.HasPrefix(got, "Name\n<script>\n+") || !strings.Contains(got, "| <script> |") {
+		t.Errorf("text tables =\n%s\nwant a csv and then an ascii table", got)
+	}
+
+	html, err := ParseTemplateFS(fsys, "report.html")
+	if err != nil {
+		t.Fatalf("ParseTemplateFS() error = %s", err)
+	}
+	got = execute(t, html, table)
+	if !strings.Contains(got, "<td>&lt;script&gt;</td>") || strings.Contains(got, "&lt;td&gt;") {
+		t.Errorf("html table = %q, want the table markup kept and its cells escaped once", got)
+	}
+
+	if _, err := embedTable(table, "nope"); err == nil {
+		t.Error("table with an unknown format did not fail")
+	}
+}
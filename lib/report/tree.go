@@ -0,0 +1,146 @@
+package report
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Node is a single entry of a Tree, Columns are shown after the label and line up across the whole tree
+type Node struct {
+	Label    string
+	Columns  []string
+	Children []*Node
+}
+
+// Tree is a hierarchy of nodes, Headers names the per node columns
+// It implements Table with the tree drawn into the first column, so every renderer can show it
+type Tree struct {
+	Headers []string
+	Roots   []*Node
+}
+
+// TreeStyle says how a TreeRenderer draws the branches of a tree
+type TreeStyle int
+
+const (
+	// TreeUnicode draws branches with box drawing characters
+	TreeUnicode TreeStyle = iota
+	// TreeIndent indents each level with spaces
+	TreeIndent
+)
+
+// TreeRenderer draws a Tree with its columns aligned to the right of the branches
+// Tables which are not a Tree are drawn as ASCIIRenderer would
+type TreeRenderer struct {
+	Style TreeStyle
+}
+
+// Add appends a child to n and returns the child, so trees can be built a level at a time
+func (n *Node) Add(label string, columns ...string) *Node {
+	child := &Node{Label: label, Columns: columns}
+	n.Children = append(n.Children, child)
+	return child
+}
+
+// Add appends a root to the tree and returns it
+func (t *Tree) Add(label string, columns ...string) *Node {
+	root := &Node{Label: label, Columns: columns}
+	t.Roots = append(t.Roots, root)
+	return root
+}
+
+// TableHeaders implements Table
+func (t Tree) TableHeaders() []string {
+	return append([]string{"Name"}, t.Headers...)
+}
+
+// TableData implements Table, the first column holds each node's label drawn with box drawing branches
+func (t Tree) TableData() [][]string {
+	var data [][]string
+	t.walk(TreeUnicode, func(prefix string, n *Node) {
+		data = append(data, append([]string{prefix + n.Label}, padRow(n.Columns, len(t.Headers))...))
+	})
+	return data
+}
+
+// walk visits every node depth first along with the branches drawn in front of its label
+func (t Tree) walk(style TreeStyle, visit func(prefix string, n *Node)) {
+	var walk func(nodes []*Node, indent string, depth int)
+	walk = func(nodes []*Node, indent string, depth int) {
+		for i, n := range nodes {
+			last := i == len(nodes)-1
+			switch {
+			case style == TreeIndent:
+				visit(strings.Repeat("  ", depth), n)
+				walk(n.Children, "", depth+1)
+			case depth == 0:
+				visit("", n)
+				walk(n.Children, "", depth+1)
+			case last:
+				visit(indent+"└── ", n)
+				walk(n.Children, indent+"    ", depth+1)
+			default:
+				visit(indent+"├── ", n)
+				walk(n.Children, indent+"│   ", depth+1)
+			}
+		}
+	}
+	walk(t.Roots, "", 0)
+}
+
+// Render implements Renderer
+func (r TreeRenderer) Render(w io.Writer, t Table) error {
+	tree, ok := t.(Tree)
+	if !ok {
+		if p, isPtr := t.(*Tree); isPtr {
+			tree, ok = *p, true
+		}
+	}
+	if !ok {
+		return ASCIIRenderer{}.Render(w, t)
+	}
+
+	type line struct {
+		name    string
+		columns []string
+	}
+	var lines []line
+	tree.walk(r.Style, func(prefix string, n *Node) {
+		lines = append(lines, line{name: prefix + n.Label, columns: padRow(n.Columns, len(tree.Headers))})
+	})
+
+	widths := make([]int, len(tree.Headers)+1)
+	for i, h := range tree.TableHeaders() {
+		widths[i] = tablewriter.DisplayWidth(h)
+	}
+	for _, l := range lines {
+		for i, cell := range append([]string{l.name}, l.columns...) {
+			if width := tablewriter.DisplayWidth(cell); width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	writeLine := func(cells []string) {
+		var padded []string
+		for i, cell := range cells {
+			if i == len(cells)-1 {
+				padded = append(padded, cell)
+				continue
+			}
+			padded = append(padded, tablewriter.PadRight(cell, " ", widths[i]))
+		}
+		bw.WriteString(strings.TrimRight(strings.Join(padded, "  "), " ") + "\n")
+	}
+	if len(tree.Headers) > 0 {
+		writeLine(tree.TableHeaders())
+	}
+	for _, l := range lines {
+		writeLine(append([]string{l.name}, l.columns...))
+	}
+	return bw.Flush()
+}
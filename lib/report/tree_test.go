@@ -0,0 +1,92 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+)
+
+// sampleTree is a deployment with two arrays, the first holding two instances
+func sampleTree() Tree {
+	tree := Tree{Headers: []string{"State", "Count"}}
+	d := tree.Add("prod", "", "2")
+	web := d.Add("web", "enabled", "2")
+	web.Add("web-1", "operational")
+	web.Add("web-2", "booting")
+	d.Add("db", "disabled", "0")
+	tree.Add("staging")
+	return tree
+}
+
+func TestTreeRendererRender(t *testing.T) {
+	tree := sampleTree()
+	tests := []struct {
+		name     string
+		renderer TreeRenderer
+		table    Table
+		want     string
+	}{
+		{
+			name:     "unicode",
+			renderer: TreeRenderer{},
+			table:    tree,
+			want: "Name           State        Count\n" +
+				"prod                        2\n" +
+				"├── web        enabled      2\n" +
+				"│   ├── web-1  operational\n" +
+				"│   └── web-2  booting\n" +
+				"└── db         disabled     0\n" +
+				"staging\n",
+		},
+		{
+			name:     "indent",
+			renderer: TreeRenderer{Style: TreeIndent},
+			table:    tree,
+			want: "Name       State        Count\n" +
+				"prod                    2\n" +
+				"  web      enabled      2\n" +
+				"    web-1  operational\n" +
+				"    web-2  booting\n" +
+				"  db       disabled     0\n" +
+				"staging\n",
+		},
+		{
+			name:     "pointer",
+			renderer: TreeRenderer{Style: TreeIndent},
+			table:    &tree,
+			want: "Name       State        Count\n" +
+				"prod                    2\n" +
+				"  web      enabled      2\n" +
+				"    web-1  operational\n" +
+				"    web-2  booting\n" +
+				"  db       disabled     0\n" +
+				"staging\n",
+		},
+		{
+			name:     "no headers",
+			renderer: TreeRenderer{},
+			table:    Tree{Roots: []*Node{{Label: "a", Children: []*Node{{Label: "b"}}}}},
+			want:     "a\n└── b\n",
+		},
+		{
+			name:     "not a tree",
+			renderer: TreeRenderer{},
+			table:    RawTable{Headers: []string{"Name"}, Data: [][]string{{"web"}}},
+			want: "+------+\n" +
+				"| NAME |\n" +
+				"+------+\n" +
+				"| web  |\n" +
+				"+------+\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.renderer.Render(&buf, tt.table); err != nil {
+				t.Fatalf("Render() error = %s", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Render() =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
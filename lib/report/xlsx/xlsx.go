@@ -0,0 +1,313 @@
+// Package xlsx writes report tables to XLSX workbooks
+// It is kept out of the report package so only programs which write workbooks build in excelize,
+// importing it registers the xlsx format with report.NewRenderer
+//
+//	import _ "github.com/angelamancini/SJP_Go_Packages/lib/report/xlsx"
+package xlsx
+
+import (
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angelamancini/SJP_Go_Packages/lib/report"
+	"github.com/pkg/errors"
+	"github.com/xuri/excelize/v2"
+)
+
+// Sheet is a table written to its own worksheet of an XLSX workbook
+type Sheet struct {
+	Name  string
+	Table report.Table
+}
+
+// Renderer writes a table as a single sheet XLSX workbook, use Write for several sheets
+type Renderer struct {
+	SheetName string
+}
+
+// sheetNameMax is the longest sheet name Excel accepts
+const sheetNameMax = 31
+
+// precision is how many significant digits Excel keeps in a number
+const precision = 15
+
+func init() {
+	report.RegisterRenderer("xlsx", Renderer{})
+}
+
+// Render implements report.Renderer
+func (r Renderer) Render(w io.Writer, t report.Table) error {
+	return Write(w, Sheet{Name: r.SheetName, Table: t})
+}
+
+// Save writes the sheets to a workbook at path, e.g.
+//
+//	xlsx.Save("inventory.xlsx", xlsx.Sheet{Name: "Arrays", Table: arrays}, xlsx.Sheet{Name: "Instances", Table: instances})
+func Save(path string, sheets ...Sheet) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Errorf("could not create workbook %s", err)
+	}
+	if err := Write(f, sheets...); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write writes the sheets to w as an XLSX workbook
+// Numbers and dates become typed cells, the header row is bold and frozen and has an autofilter
+// Tables with a footer get it as a bold row below the data, outside the autofilter
+func Write(w io.Writer, sheets ...Sheet) error {
+	if len(sheets) == 0 {
+		return errors.New("could not write workbook, no sheets given")
+	}
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newStyles(f)
+	if err != nil {
+		return err
+	}
+	used := map[string]bool{}
+	for n, sheet := range sheets {
+		name := sheetName(sheet.Name, n+1, used)
+		if n == 0 {
+			err = f.SetSheetName(f.GetSheetName(0), name)
+		} else {
+			_, err = f.NewSheet(name)
+		}
+		if err != nil {
+			return errors.Errorf("could not add sheet %s %s", name, err)
+		}
+		if err := writeSheet(f, name, sheet.Table, styles); err != nil {
+			return errors.WithMessage(err, "could not write sheet "+name)
+		}
+	}
+	f.SetActiveSheet(0)
+	if err := f.Write(w); err != nil {
+		return errors.Errorf("could not write workbook %s", err)
+	}
+	return nil
+}
+
+// styles are the style ids shared by every sheet of a workbook, the bold dates are for footers
+type styles struct {
+	header       int
+	date         int
+	dateTime     int
+	boldDate     int
+	boldDateTime int
+}
+
+// newStyles registers the workbook's styles
+func newStyles(f *excelize.File) (styles, error) {
+	var s styles
+	var err error
+	date, dateTime := "yyyy-mm-dd", "yyyy-mm-dd hh:mm:ss"
+	if s.header, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}}); err != nil {
+		return s, errors.Errorf("could not create header style %s", err)
+	}
+	if s.date, err = f.NewStyle(&excelize.Style{CustomNumFmt: &date}); err != nil {
+		return s, errors.Errorf("could not create date style %s", err)
+	}
+	if s.dateTime, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateTime}); err != nil {
+		return s, errors.Errorf("could not create date style %s", err)
+	}
+	bold := &excelize.Font{Bold: true}
+	if s.boldDate, err = f.NewStyle(&excelize.Style{Font: bold, CustomNumFmt: &date}); err != nil {
+		return s, errors.Errorf("could not create date style %s", err)
+	}
+	if s.boldDateTime, err = f.NewStyle(&excelize.Style{Font: bold, CustomNumFmt: &dateTime}); err != nil {
+		return s, errors.Errorf("could not create date style %s", err)
+	}
+	return s, nil
+}
+
+// writeSheet fills one worksheet with a table
+func writeSheet(f *excelize.File, sheet string, t report.Table, styles styles) error {
+	headers := t.TableHeaders()
+	if len(headers) == 0 {
+		return nil
+	}
+	widths := make([]int, len(headers))
+	header := make([]interface{}, len(headers))
+	for i, h := range headers {
+		header[i] = h
+		widths[i] = len(h)
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+
+	row := 2
+	//bold rows are styled cell by cell so their dates keep a date format
+	writeRow := func(cells []string, bold bool) error {
+		values := make([]interface{}, len(headers))
+		var dates []int
+		for i, cell := range padRow(cells, len(headers)) {
+			values[i] = cellValue(cell)
+			if _, ok := values[i].(time.Time); ok {
+				dates = append(dates, i)
+			}
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+		start, _ := excelize.CoordinatesToCellName(1, row)
+		if err := f.SetSheetRow(sheet, start, &values); err != nil {
+			return err
+		}
+		if bold {
+			end, _ := excelize.CoordinatesToCellName(len(headers), row)
+			if err := f.SetCellStyle(sheet, start, end, styles.header); err != nil {
+				return err
+			}
+		}
+		for _, i := range dates {
+			cell, _ := excelize.CoordinatesToCellName(i+1, row)
+			style, dateStyle := styles.dateTime, styles.date
+			if bold {
+				style, dateStyle = styles.boldDateTime, styles.boldDate
+			}
+			if d := values[i].(time.Time); d.Equal(time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, d.Location())) {
+				style = dateStyle
+			}
+			if err := f.SetCellStyle(sheet, cell, cell, style); err != nil {
+				return err
+			}
+		}
+		row++
+		return nil
+	}
+	for _, cells := range t.TableData() {
+		if err := writeRow(cells, false); err != nil {
+			return err
+		}
+	}
+	lastData := row - 1
+
+	if footer := tableFooter(t); footer != nil {
+		if err := writeRow(footer, true); err != nil {
+			return err
+		}
+	}
+	if err := f.SetRowStyle(sheet, 1, 1, styles.header); err != nil {
+		return err
+	}
+	if err := f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+	last, _ := excelize.CoordinatesToCellName(len(headers), lastData)
+	if err := f.AutoFilter(sheet, "A1:"+last, nil); err != nil {
+		return err
+	}
+	for i, width := range widths {
+		col, _ := excelize.ColumnNumberToName(i + 1)
+		if width > 60 {
+			width = 60
+		}
+		if err := f.SetColWidth(sheet, col, col, float64(width+2)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cellValue converts a cell to the value written to the workbook: a number, a date or the string itself
+// numbers with leading zeros and numbers with more digits than Excel keeps (15) stay strings so ids are not
+// mangled, as do NaN and Inf which ParseFloat accepts but Excel cannot store
+// dates are tried against report.DateLayouts
+func cellValue(cell string) interface{} {
+	s := strings.TrimSpace(cell)
+	if s == "" {
+		return cell
+	}
+	digits := strings.TrimLeft(s, "+-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return cell
+	}
+	if countDigits(digits) <= precision {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+	}
+	for _, layout := range report.DateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return cell
+}
+
+// countDigits counts the digits of a number's mantissa, e.g. 3 for 1.25e10
+func countDigits(s string) int {
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		s = s[:i]
+	}
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+// sheetName makes a name Excel will accept and which no earlier sheet has used
+func sheetName(name string, n int, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	name = strings.Trim(name, "'")
+	if name == "" {
+		name = "Sheet" + strconv.Itoa(n)
+	}
+	if runes := []rune(name); len(runes) > sheetNameMax {
+		name = string(runes[:sheetNameMax])
+	}
+	unique := name
+	for i := 2; used[strings.ToLower(unique)]; i++ {
+		suffix := " (" + strconv.Itoa(i) + ")"
+		runes := []rune(name)
+		if len(runes)+len(suffix) > sheetNameMax {
+			runes = runes[:sheetNameMax-len(suffix)]
+		}
+		unique = string(runes) + suffix
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+// tableFooter returns the footer of a table which has one padded to the width of the headers, or nil
+func tableFooter(t report.Table) []string {
+	f, ok := t.(report.Footer)
+	if !ok {
+		return nil
+	}
+	footer := f.TableFooter()
+	if len(footer) == 0 {
+		return nil
+	}
+	return padRow(footer, len(t.TableHeaders()))
+}
+
+// padRow returns row with exactly width cells, missing cells are empty and extra cells are dropped
+func padRow(row []string, width int) []string {
+	if len(row) == width {
+		return row
+	}
+	padded := make([]string, width)
+	copy(padded, row)
+	return padded
+}
@@ -0,0 +1,190 @@
+package xlsx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/angelamancini/SJP_Go_Packages/lib/report"
+	"github.com/xuri/excelize/v2"
+)
+
+// openWorkbook writes sheets to a workbook and opens it again with excelize
+func openWorkbook(t *testing.T, sheets ...Sheet) *excelize.File {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, sheets...); err != nil {
+		t.Fatalf("Write() error = %s", err)
+	}
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatalf("could not open workbook %s", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// cellStyle returns the style of a cell
+func cellStyle(t *testing.T, f *excelize.File, sheet, cell string) *excelize.Style {
+	t.Helper()
+	id, err := f.GetCellStyle(sheet, cell)
+	if err != nil {
+		t.Fatalf("GetCellStyle(%s) error = %s", cell, err)
+	}
+	style, err := f.GetStyle(id)
+	if err != nil {
+		t.Fatalf("GetStyle(%s) error = %s", cell, err)
+	}
+	return style
+}
+
+func TestWriteCells(t *testing.T) {
+	table := report.RawTable{Headers: []string{"Value"}, Data: [][]string{
+		{"42"},
+		{"-2.5"},
+		{"007"},
+		{"NaN"},
+		{"Inf"},
+		{"1234567890123456"},
+		{"123456789012345"},
+		{"1234567890.123456"},
+		{"2020-01-02"},
+		{"2020/01/02 13:04:05 +0000"},
+		{"web"},
+		{""},
+	}}
+	f := openWorkbook(t, Sheet{Name: "Cells", Table: table})
+	tests := []struct {
+		cell   string
+		number bool
+		value  string
+		format string
+	}{
+		{cell: "A2", number: true, value: "42"},
+		{cell: "A3", number: true, value: "-2.5"},
+		{cell: "A4", value: "007"},
+		{cell: "A5", value: "NaN"},
+		{cell: "A6", value: "Inf"},
+		{cell: "A7", value: "1234567890123456"},
+		{cell: "A8", number: true, value: "123456789012345"},
+		{cell: "A9", value: "1234567890.123456"},
+		{cell: "A10", number: true, value: "43832", format: "yyyy-mm-dd"},
+		{cell: "A11", number: true, value: "43832.5445", format: "yyyy-mm-dd hh:mm:ss"},
+		{cell: "A12", value: "web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cell, func(t *testing.T) {
+			typ, err := f.GetCellType("Cells", tt.cell)
+			if err != nil {
+				t.Fatalf("GetCellType() error = %s", err)
+			}
+			//excelize leaves the type of number cells unset
+			if number := typ == excelize.CellTypeUnset || typ == excelize.CellTypeNumber; number != tt.number {
+				t.Errorf("cell type = %d, want number %t", typ, tt.number)
+			}
+			value, err := f.GetCellValue("Cells", tt.cell, excelize.Options{RawCellValue: true})
+			if err != nil {
+				t.Fatalf("GetCellValue() error = %s", err)
+			}
+			if !strings.HasPrefix(value, tt.value) {
+				t.Errorf("value = %q, want %q", value, tt.value)
+			}
+			format := ""
+			if style := cellStyle(t, f, "Cells", tt.cell); style.CustomNumFmt != nil {
+				format = *style.CustomNumFmt
+			}
+			if format != tt.format {
+				t.Errorf("number format = %q, want %q", format, tt.format)
+			}
+		})
+	}
+}
+
+func TestWriteLayout(t *testing.T) {
+	table := report.RawTable{Headers: []string{"Name", "Count", "Updated"}, Data: [][]string{
+		{"web", "2", "2020-01-02"},
+		{"db", "1", "2020-01-03"},
+	}, Footer: []string{"Total", "3", "2020-01-03"}}
+	f := openWorkbook(t, Sheet{Name: "Arrays", Table: table})
+
+	panes, err := f.GetPanes("Arrays")
+	if err != nil {
+		t.Fatalf("GetPanes() error = %s", err)
+	}
+	if !panes.Freeze || panes.YSplit != 1 || panes.TopLeftCell != "A2" {
+		t.Errorf("panes = %+v, want the header row frozen", panes)
+	}
+
+	var filter string
+	for _, name := range f.GetDefinedName() {
+		if name.Name == "_xlnm._FilterDatabase" {
+			filter = name.RefersTo
+		}
+	}
+	if filter != "'Arrays'!$A$1:$C$3" && filter != "Arrays!$A$1:$C$3" {
+		t.Errorf("autofilter = %q, want the header and data rows without the footer", filter)
+	}
+
+	if style := cellStyle(t, f, "Arrays", "A1"); style.Font == nil || !style.Font.Bold {
+		t.Error("header is not bold")
+	}
+	for _, cell := range []string{"A4", "B4", "C4"} {
+		if style := cellStyle(t, f, "Arrays", cell); style.Font == nil || !style.Font.Bold {
+			t.Errorf("footer cell %s is not bold", cell)
+		}
+	}
+	if style := cellStyle(t, f, "Arrays", "C4"); style.CustomNumFmt == nil || *style.CustomNumFmt != "yyyy-mm-dd" {
+		t.Error("footer date lost its date format")
+	}
+	if style := cellStyle(t, f, "Arrays", "A2"); style.Font != nil && style.Font.Bold {
+		t.Error("data row is bold")
+	}
+}
+
+func TestWriteSheets(t *testing.T) {
+	table := report.RawTable{Headers: []string{"Name"}, Data: [][]string{{"web"}}}
+	f := openWorkbook(t,
+		Sheet{Name: "Arrays", Table: table},
+		Sheet{Name: "arrays", Table: table},
+		Sheet{Name: "a/b:c", Table: table},
+		Sheet{Table: table})
+	want := "Arrays,arrays (2),a_b_c,Sheet4"
+	if got := strings.Join(f.GetSheetList(), ","); got != want {
+		t.Errorf("sheets = %q, want %q", got, want)
+	}
+	if err := Write(&bytes.Buffer{}); err == nil {
+		t.Error("Write() with no sheets did not fail")
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	long := strings.Repeat("x", 40)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Arrays", want: "Arrays"},
+		{name: " [a]:*?/\\ ", want: "_a______"},
+		{name: "'quoted'", want: "quoted"},
+		{name: "", want: "Sheet3"},
+		{name: long, want: long[:31]},
+		{name: "ARRAYS", want: "ARRAYS (2)"},
+		{name: long, want: long[:27] + " (2)"},
+	}
+	used := map[string]bool{}
+	for _, tt := range tests {
+		if got := sheetName(tt.name, 3, used); got != tt.want {
+			t.Errorf("sheetName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRendererIsRegistered(t *testing.T) {
+	var buf bytes.Buffer
+	if err := report.Render(&buf, report.RawTable{Headers: []string{"Name"}}, "xlsx"); err != nil {
+		t.Fatalf("Render() error = %s", err)
+	}
+	if _, err := excelize.OpenReader(&buf); err != nil {
+		t.Errorf("could not open rendered workbook %s", err)
+	}
+}
@@ -0,0 +1,115 @@
+package rightscale
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultAccountParallelism is the number of accounts Each works on at once
+const DefaultAccountParallelism = 4
+
+// Account represents a single Rightscale account
+type Account struct {
+	Name      string  `json:"name"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+	Links     rsLinks `json:"links"`
+}
+
+// Accounts represents a collection of Account resources
+type Accounts []Account
+
+// AccountErrors collects the errors from running a function across several accounts, keyed by account ID
+type AccountErrors map[string]error
+
+// ID returns the numeric portion at the end of an account's Href
+func (a Account) ID() string {
+	stringParts := strings.Split(a.Links.LinkValue("self"), "/")
+	return stringParts[len(stringParts)-1]
+}
+
+// Error implements the error interface
+func (ae AccountErrors) Error() string {
+	var ids []string
+	for id := range ae {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var loopErrors []string
+	for _, id := range ids {
+		loopErrors = append(loopErrors, fmt.Sprintf("account %s: %s", id, ae[id]))
+	}
+	return fmt.Sprintf("%d accounts failed - %s", len(ae), strings.Join(loopErrors, "; "))
+}
+
+// Accounts returns every account the client's session has access to
+func (c Client) Accounts() (Accounts, error) {
+	return c.listAccounts("/api/sessions/accounts")
+}
+
+// ChildAccounts returns the child accounts of the client's account, the account must be an enterprise parent
+func (c Client) ChildAccounts() (Accounts, error) {
+	return c.listAccounts("/api/child_accounts")
+}
+
+// listAccounts returns the accounts listed at path
+func (c Client) listAccounts(path string) (Accounts, error) {
+	accountListParams := RequestParams{
+		method: "GET",
+		url:    path,
+	}
+	var accounts Accounts
+	data, err := c.Request(accountListParams)
+	if err != nil {
+		return accounts, errors.Errorf("encountered error requesting accounts %s", err)
+	}
+	err = json.Unmarshal(data, &accounts)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not unmarshal Accounts response")
+	}
+	return accounts, nil
+}
+
+// ForAccount returns a copy of the client which makes its requests against the given account ID
+// The client's token must belong to a user with access to that account. Roles differ between accounts
+// so any preflight is dropped, call WithPreflight on the returned client to check roles in the new account
+func (c Client) ForAccount(accountID string) Client {
+	c.Account = accountID
+	c.preflight = nil
+	return c
+}
+
+// Each runs fn once per account with a client scoped to that account
+// Accounts are worked on concurrently, DefaultAccountParallelism at a time. fn is responsible for storing its own
+// results and must be safe to call from several goroutines. Every account is attempted, the returned error is
+// nil or an AccountErrors holding the error from each failed account
+func (c Client) Each(accounts Accounts, fn func(c Client, account Account) error) error {
+	var mu sync.Mutex
+	failed := AccountErrors{}
+	slots := make(chan struct{}, DefaultAccountParallelism)
+	var loopGroup sync.WaitGroup
+	for _, account := range accounts {
+		loopGroup.Add(1)
+		slots <- struct{}{}
+		go func(account Account) {
+			defer loopGroup.Done()
+			err := fn(c.ForAccount(account.ID()), account)
+			<-slots
+			if err != nil {
+				mu.Lock()
+				failed[account.ID()] = err
+				mu.Unlock()
+			}
+		}(account)
+	}
+	loopGroup.Wait()
+	if len(failed) != 0 {
+		return failed
+	}
+	return nil
+}
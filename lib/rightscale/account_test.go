@@ -0,0 +1,50 @@
+package rightscale
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEachAccountArrays(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.on("GET", "/api/sessions/accounts", 200, `[{"name":"one","links":[{"rel":"self","href":"/api/accounts/1"}]},`+
+		`{"name":"two","links":[{"rel":"self","href":"/api/accounts/2"}]},`+
+		`{"name":"three","links":[{"rel":"self","href":"/api/accounts/3"}]}]`)
+	api.serveDeployment("1")
+	api.onAccount("2", "GET", "/api/deployments", 200, "["+deploymentJSON+"]")
+	api.onAccount("2", "GET", "/api/deployments/1/server_arrays?view=instance_detail", 200,
+		"["+arrayJSON+","+strings.Replace(arrayJSON, `"a1"`, `"a2"`, 1)+"]")
+
+	accounts, err := c.Accounts()
+	if err != nil {
+		t.Fatalf("Accounts() error = %s", err)
+	}
+	var mu sync.Mutex
+	arraysByAccount := map[string][]string{}
+	err = c.Each(accounts, func(c Client, account Account) error {
+		arrays, err := c.Arrays()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, a := range arrays {
+			arraysByAccount[account.ID()] = append(arraysByAccount[account.ID()], a.Name)
+		}
+		return nil
+	})
+
+	failed, ok := err.(AccountErrors)
+	if !ok || len(failed) != 1 || failed["3"] == nil {
+		t.Fatalf("Each() error = %v, want only account 3 to fail", err)
+	}
+	sort.Strings(arraysByAccount["2"])
+	if got := strings.Join(arraysByAccount["1"], ","); got != "a1" {
+		t.Errorf("account 1 arrays = %q, want a1", got)
+	}
+	if got := strings.Join(arraysByAccount["2"], ","); got != "a1,a2" {
+		t.Errorf("account 2 arrays = %q, want a1,a2", got)
+	}
+}
@@ -0,0 +1,319 @@
+package rightscale
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultAlertSpecParallelism is the number of arrays ArraysWithoutVoters checks at once
+const DefaultAlertSpecParallelism = 5
+
+// AlertSpec represents a single alert specification in Rightscale
+// Alert specs can be attached to arrays, servers and server templates. An alert spec either escalates
+// (EscalationName is set) or votes on array scaling (VoteTag and VoteType are set)
+type AlertSpec struct {
+	Href           string
+	Condition      string  `json:"condition"`
+	CreatedAt      string  `json:"created_at"`
+	Description    string  `json:"description"`
+	Duration       int     `json:"duration"`
+	EscalationName string  `json:"escalation_name"`
+	File           string  `json:"file"`
+	Links          rsLinks `json:"links"`
+	Name           string  `json:"name"`
+	Threshold      string  `json:"threshold"`
+	UpdatedAt      string  `json:"updated_at"`
+	Variable       string  `json:"variable"`
+	VoteTag        string  `json:"vote_tag"`
+	VoteType       string  `json:"vote_type"`
+}
+
+// AlertSpecs represents a collection of AlertSpec resources
+type AlertSpecs []AlertSpec
+
+// IsVoter reports whether the alert spec casts a grow or shrink vote for array scaling
+func (as AlertSpec) IsVoter() bool {
+	return as.VoteTag != "" && (as.VoteType == "grow" || as.VoteType == "shrink")
+}
+
+// id returns the value of the href named self
+func (as AlertSpec) id() string {
+	return as.Links.LinkValue("self")
+}
+
+// validate checks that the alert spec has the fields Rightscale requires before it is sent
+func (as AlertSpec) validate() error {
+	if as.Name == "" || as.File == "" || as.Variable == "" || as.Condition == "" || as.Threshold == "" {
+		return errors.New("alert spec requires name, file, variable, condition and threshold")
+	}
+	voting := as.VoteTag != "" || as.VoteType != ""
+	if voting && as.EscalationName != "" {
+		return errors.New("alert spec cannot have both an escalation name and a vote tag/type")
+	}
+	if voting && !as.IsVoter() {
+		return errors.Errorf("alert spec vote type must be grow or shrink with a vote tag, got %q/%q", as.VoteTag, as.VoteType)
+	}
+	if !voting && as.EscalationName == "" {
+		return errors.New("alert spec requires either an escalation name or a vote tag and vote type")
+	}
+	return nil
+}
+
+// params builds the request body Rightscale expects for create and update calls
+func (as AlertSpec) params(subjectHref string) map[string]map[string]string {
+	p := map[string]string{
+		"name":      as.Name,
+		"file":      as.File,
+		"variable":  as.Variable,
+		"condition": as.Condition,
+		"threshold": as.Threshold,
+		"duration":  strconv.Itoa(as.Duration),
+	}
+	if as.Description != "" {
+		p["description"] = as.Description
+	}
+	if as.IsVoter() {
+		p["vote_tag"] = as.VoteTag
+		p["vote_type"] = as.VoteType
+	} else {
+		p["escalation_name"] = as.EscalationName
+	}
+	if subjectHref != "" {
+		p["subject_href"] = subjectHref
+	}
+	return map[string]map[string]string{"alert_spec": p}
+}
+
+// AlertSpecs returns the alert specs attached to a subject
+// The subjectHref is the full href of a server array, server or server template e.g. /api/server_arrays/123
+func (c Client) AlertSpecs(subjectHref string) (AlertSpecs, error) {
+	alertSpecListParams := RequestParams{
+		method: "GET",
+		url:    fmt.Sprintf("%s/alert_specs", subjectHref),
+	}
+	var specs AlertSpecs
+	data, err := c.Request(alertSpecListParams)
+	if err != nil {
+		return specs, errors.Errorf("encountered error requesting alert specs for %s, %s", subjectHref, err)
+	}
+	err = json.Unmarshal(data, &specs)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error parsing response in alert specs function")
+	}
+	for i := range specs {
+		specs[i].Href = specs[i].id()
+	}
+	return specs, nil
+}
+
+// ArrayAlertSpecs returns the alert specs attached directly to a server array
+func (c Client) ArrayAlertSpecs(array ServerArray) (AlertSpecs, error) {
+	return c.AlertSpecs(array.id())
+}
+
+// AlertSpec retrieves a single alert spec by its numeric ID
+// If you have an alert spec's href split by / and take the last part. that is the ID.
+func (c Client) AlertSpec(alertSpecID string) (spec AlertSpec, e error) {
+	alertSpecRequestParams := RequestParams{
+		method: "GET",
+		url:    fmt.Sprintf("/api/alert_specs/%s", alertSpecID),
+	}
+	data, err := c.Request(alertSpecRequestParams)
+	if err != nil {
+		return AlertSpec{}, errors.Errorf("encountered error requesting alert spec %s", err)
+	}
+	err = json.Unmarshal(data, &spec)
+	if err != nil {
+		return AlertSpec{}, errors.WithMessage(err, "could not unmarshal AlertSpec response")
+	}
+	spec.Href = spec.id()
+	return
+}
+
+// CreateAlertSpec creates a new alert spec on the given subject and returns the href of the new resource
+// The subjectHref is the full href of a server array, server or server template
+// 201 is the only expected status code for this call
+func (c Client) CreateAlertSpec(subjectHref string, spec AlertSpec) (string, error) {
+	if err := c.checkRoles("create alert spec"); err != nil {
+		return "", err
+	}
+	if err := spec.validate(); err != nil {
+		return "", err
+	}
+	createParams := RequestParams{
+		method: "POST",
+		url:    "/api/alert_specs",
+		body:   spec.params(subjectHref),
+	}
+	resp, err := c.RequestDetailed(createParams)
+	if err != nil {
+		return "", errors.WithMessage(err, "Error calling create alert spec endpoint")
+	}
+	if resp.StatusCode == 201 {
+		return resp.Header.Get("Location"), nil
+	}
+	return "", unexpectedStatus("create alert spec", 201, resp.StatusCode, resp.Body)
+}
+
+// UpdateAlertSpec updates an existing alert spec, the spec's Href must be set
+// 204 is the only expected status code for this call
+func (c Client) UpdateAlertSpec(spec AlertSpec) error {
+	if err := c.checkRoles("update alert spec"); err != nil {
+		return err
+	}
+	if spec.Href == "" {
+		return errors.New("alert spec has no href, it must be retrieved from Rightscale before updating")
+	}
+	if err := spec.validate(); err != nil {
+		return err
+	}
+	updateParams := RequestParams{
+		method: "PUT",
+		url:    spec.Href,
+		body:   spec.params(""),
+	}
+	resp, err := c.RequestDetailed(updateParams)
+	if err != nil {
+		return errors.WithMessage(err, "Error calling update alert spec endpoint")
+	}
+	if resp.StatusCode == 204 {
+		return nil
+	}
+	return unexpectedStatus("update alert spec", 204, resp.StatusCode, resp.Body)
+}
+
+// DeleteAlertSpec deletes the alert spec with the given href
+// 204 is the only expected status code for this call
+func (c Client) DeleteAlertSpec(alertSpecHref string) error {
+	if err := c.checkRoles("delete alert spec"); err != nil {
+		return err
+	}
+	deleteParams := RequestParams{
+		method: "DELETE",
+		url:    alertSpecHref,
+	}
+	resp, err := c.RequestDetailed(deleteParams)
+	if err != nil {
+		return errors.WithMessage(err, "Error calling delete alert spec endpoint")
+	}
+	if resp.StatusCode == 204 {
+		return nil
+	}
+	return unexpectedStatus("delete alert spec", 204, resp.StatusCode, resp.Body)
+}
+
+// NonVotingArray is a single row of the non voting array report
+type NonVotingArray struct {
+	Name               string
+	Href               string
+	ArrayType          string
+	VotersTagPredicate string
+	AlertSpecCount     int
+}
+
+// NonVotingArrays is the result of ArraysWithoutVoters, it implements the report.Table interface
+type NonVotingArrays []NonVotingArray
+
+// TableHeaders returns the headers for the non voting array report
+func (n NonVotingArrays) TableHeaders() []string {
+	return []string{"Name", "Href", "Type", "Voters Tag Predicate", "Alert Specs"}
+}
+
+// TableData returns the rows for the non voting array report
+func (n NonVotingArrays) TableData() [][]string {
+	var data [][]string
+	for _, a := range n {
+		data = append(data, []string{a.Name, a.Href, a.ArrayType, a.VotersTagPredicate, strconv.Itoa(a.AlertSpecCount)})
+	}
+	return data
+}
+
+// ArraysWithoutVoters returns the alert based arrays which have no voting alert specs and so can never scale
+// An alert spec votes for an array when its vote tag matches the array's voters tag predicate. Alert specs attached
+// to the array and to the server template of its next instance are both considered. At most
+// DefaultAlertSpecParallelism arrays are checked at once and the result is sorted by array name
+func (c Client) ArraysWithoutVoters(arrays ServerArrays) (NonVotingArrays, error) {
+	var results NonVotingArrays
+	var loopErrors []string
+	var mu sync.Mutex
+	slots := make(chan struct{}, DefaultAlertSpecParallelism)
+	var loopGroup sync.WaitGroup
+	for _, array := range arrays {
+		if array.ArrayType != "alert" {
+			continue
+		}
+		loopGroup.Add(1)
+		slots <- struct{}{}
+		go func(array ServerArray) {
+			defer loopGroup.Done()
+			voters, total, err := c.arrayVoterCount(array)
+			<-slots
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				loopErrors = append(loopErrors, err.Error())
+				return
+			}
+			if voters == 0 {
+				results = append(results, NonVotingArray{
+					Name:               array.Name,
+					Href:               array.id(),
+					ArrayType:          array.ArrayType,
+					VotersTagPredicate: array.ElasticityParams.AlertSpecificParams.VotersTagPredicate,
+					AlertSpecCount:     total,
+				})
+			}
+		}(array)
+	}
+	loopGroup.Wait()
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Name == results[j].Name {
+			return results[i].Href < results[j].Href
+		}
+		return results[i].Name < results[j].Name
+	})
+	if len(loopErrors) != 0 {
+		sort.Strings(loopErrors)
+		return results, errors.Errorf("encountered %d errors checking array alert specs, first error %s", len(loopErrors), loopErrors[0])
+	}
+	return results, nil
+}
+
+// arrayVoterCount returns the number of voting alert specs and the total number of alert specs for an array
+func (c Client) arrayVoterCount(array ServerArray) (voters int, total int, e error) {
+	subjects := []string{array.id()}
+	if templateHref := array.NextInstance.Links.LinkValue("server_template"); templateHref != "" {
+		subjects = append(subjects, templateHref)
+	}
+	predicate := array.ElasticityParams.AlertSpecificParams.VotersTagPredicate
+	for _, subject := range subjects {
+		specs, err := c.AlertSpecs(subject)
+		if err != nil {
+			return 0, 0, errors.Errorf("could not list alert specs for array %s - %s", array.Name, err)
+		}
+		for _, spec := range specs {
+			total++
+			if spec.IsVoter() && spec.VoteTag == predicate {
+				voters++
+			}
+		}
+	}
+	return
+}
+
+// unexpectedStatus builds an error for a response which did not carry the expected status code
+// the response body is included in the error where it can be read
+func unexpectedStatus(action string, expected int, got int, body io.Reader) error {
+	responseBody, err := ioutil.ReadAll(body)
+	if err != nil || len(responseBody) == 0 {
+		return errors.Errorf("Error calling %s endpoint expected %d got %d", action, expected, got)
+	}
+	return errors.Errorf("Error calling %s endpoint expected %d got %d - %s", action, expected, got, string(responseBody))
+}
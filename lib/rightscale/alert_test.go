@@ -0,0 +1,72 @@
+package rightscale
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrencyLimit wraps a handler and records the most requests it saw in flight at once
+type concurrencyLimit struct {
+	next     http.Handler
+	mu       sync.Mutex
+	inFlight int
+	most     int
+}
+
+func (l *concurrencyLimit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	l.inFlight++
+	if l.inFlight > l.most {
+		l.most = l.inFlight
+	}
+	l.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	l.next.ServeHTTP(w, r)
+	l.mu.Lock()
+	l.inFlight--
+	l.mu.Unlock()
+}
+
+func TestArraysWithoutVoters(t *testing.T) {
+	api := &fakeAPI{routes: map[string]fakeResponse{}}
+	limit := &concurrencyLimit{next: api}
+	server := httptest.NewServer(limit)
+	defer server.Close()
+	c := Client{EndPoint: server.URL}
+
+	var arrays ServerArrays
+	for n := 20; n > 0; n-- {
+		var a ServerArray
+		a.Name = fmt.Sprintf("array-%02d", n)
+		a.ArrayType = "alert"
+		a.ElasticityParams.AlertSpecificParams.VotersTagPredicate = "web"
+		a.Links = rsLinks{{Rel: "self", Href: fmt.Sprintf("/api/server_arrays/%d", n)}}
+		specs := `[{"name":"cpu","escalation_name":"critical"}]`
+		if n%2 == 0 {
+			specs = `[{"name":"cpu","vote_tag":"web","vote_type":"grow"}]`
+		}
+		api.on("GET", fmt.Sprintf("/api/server_arrays/%d/alert_specs", n), 200, specs)
+		arrays = append(arrays, a)
+	}
+	arrays = append(arrays, ServerArray{Name: "queue", ArrayType: "queue"})
+
+	results, err := c.ArraysWithoutVoters(arrays)
+	if err != nil {
+		t.Fatalf("ArraysWithoutVoters() error = %s", err)
+	}
+	if len(results) != 10 {
+		t.Fatalf("ArraysWithoutVoters() = %d arrays, want the 10 without voters", len(results))
+	}
+	for n, r := range results {
+		if want := fmt.Sprintf("array-%02d", n*2+1); r.Name != want || r.AlertSpecCount != 1 {
+			t.Errorf("result %d = %s with %d specs, want %s with 1", n, r.Name, r.AlertSpecCount, want)
+		}
+	}
+	if limit.most > DefaultAlertSpecParallelism {
+		t.Errorf("%d requests were in flight at once, want at most %d", limit.most, DefaultAlertSpecParallelism)
+	}
+}
@@ -3,9 +3,9 @@ package rightscale
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"github.com/pkg/errors"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
@@ -18,9 +18,83 @@ type Bearer struct {
 	TokenType   string `json:"token_type"`
 }
 
+// KnownEndpoints are the Rightscale shards probed when a client is built without an endpoint
+var KnownEndpoints = []string{"https://us-3.rightscale.com", "https://us-4.rightscale.com"}
+
+// maxShardRedirects caps how many account redirects are followed while looking for a token's shard
+const maxShardRedirects = 3
+
+// shardRedirect is returned by requestBearerToken when Rightscale points the token at another shard
+type shardRedirect struct {
+	endPoint string
+}
+
+func (r *shardRedirect) Error() string {
+	return fmt.Sprintf("refresh token belongs to shard %s", r.endPoint)
+}
+
+// DiscoverEndpoint finds the shard endpoint a refresh token belongs to and returns it along with a bearer token
+// Account redirects from Rightscale are followed, if there are none each candidate is probed in order.
+// When no candidates are given KnownEndpoints are probed
+func DiscoverEndpoint(refreshToken string, candidates ...string) (endPoint string, bearerToken string, e error) {
+	return Client{}.discoverEndpoint(refreshToken, candidates...)
+}
+
+// discoverEndpoint is DiscoverEndpoint calling the client's hooks around every probe
+func (c Client) discoverEndpoint(refreshToken string, candidates ...string) (endPoint string, bearerToken string, e error) {
+	if len(candidates) == 0 {
+		candidates = KnownEndpoints
+	}
+	var probeErrors []string
+	for _, candidate := range candidates {
+		endPoint, bearerToken, err := c.bearerTokenFollowingRedirects(refreshToken, candidate)
+		if err == nil {
+			return endPoint, bearerToken, nil
+		}
+		probeErrors = append(probeErrors, fmt.Sprintf("%s: %s", candidate, err))
+	}
+	return "", "", errors.Errorf("could not find endpoint for refresh token - %s", strings.Join(probeErrors, "; "))
+}
+
+// bearerTokenFollowingRedirects requests a bearer token from endPoint, following any redirect to another shard
+// the endpoint which issued the token is returned with it
+func (c Client) bearerTokenFollowingRedirects(refreshToken string, endPoint string) (string, string, error) {
+	for i := 0; i <= maxShardRedirects; i++ {
+		token, err := c.requestBearerToken(refreshToken, endPoint)
+		if redirect, ok := err.(*shardRedirect); ok {
+			endPoint = redirect.endPoint
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return endPoint, token, nil
+	}
+	return "", "", errors.Errorf("too many shard redirects, last endpoint %s", endPoint)
+}
+
 func getBearerToken(refreshToken string, endPoint string) (string, error) {
+	return Client{}.authenticate(refreshToken, endPoint)
+}
+
+// authenticate exchanges a refresh token for a bearer token at endPoint, calling the client's hooks around the request
+func (c Client) authenticate(refreshToken string, endPoint string) (string, error) {
+	token, err := c.requestBearerToken(refreshToken, endPoint)
+	if redirect, ok := err.(*shardRedirect); ok {
+		return "", errors.Errorf("wrong endpoint %s for refresh token, it belongs to %s", endPoint, redirect.endPoint)
+	}
+	return token, err
+}
+
+// requestBearerToken exchanges a refresh token for a bearer token at a single endpoint
+// redirects are not followed, a *shardRedirect is returned naming the endpoint Rightscale redirected to
+func (c Client) requestBearerToken(refreshToken string, endPoint string) (token string, e error) {
 	data := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}
-	client := http.Client{}
+	client := http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
 	path := strings.Join([]string{endPoint, "/api/oauth2"}, "")
 	req, err := http.NewRequest("POST", path, bytes.NewBufferString(data.Encode()))
 
@@ -29,21 +103,37 @@ func getBearerToken(refreshToken string, endPoint string) (string, error) {
 	}
 	req.Header.Add("X_API_VERSION", "1.5")
 	req.Header.Add("accept", "json")
+	info := c.beforeRequest(req.Method, path)
 	response, err := client.Do(req)
+	statusCode := 0
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+	defer func() { c.afterRequest(info, statusCode, e) }()
 
 	if err != nil {
 		return "", errors.Errorf("An error was encountered retrieving bearer token from RS %s", err)
 	}
 	defer response.Body.Close()
+	if response.StatusCode >= 300 && response.StatusCode < 400 {
+		location, err := response.Location()
+		if err != nil {
+			return "", errors.Errorf("bearer token request was redirected without a location %s", err)
+		}
+		return "", &shardRedirect{endPoint: fmt.Sprintf("%s://%s", location.Scheme, location.Host)}
+	}
 	ResponseText, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		log.Fatalln("An error was encountered reading response data from bearer token request")
+		return "", errors.Errorf("An error was encountered reading response data from bearer token request %s", err)
+	}
+	if response.StatusCode != 200 {
+		return "", errors.Errorf("bearer token request to %s expected 200 got %d - %s", endPoint, response.StatusCode, strings.TrimSpace(string(ResponseText)))
 	}
 	result := Bearer{}
 	err = json.Unmarshal([]byte(ResponseText), &result)
 	if err != nil {
 		return "", errors.Errorf("Could not unmarshal json from oauth call %s", err)
 	}
-	token := strings.Join([]string{"Bearer", result.AccessToken}, " ")
+	token = strings.Join([]string{"Bearer", result.AccessToken}, " ")
 	return token, nil
 }
@@ -0,0 +1,138 @@
+package rightscale
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newShard starts a fake oauth endpoint which gives a bearer token for the refresh token "good"
+// and redirects every request when redirect returns a location
+func newShard(t *testing.T, redirect func() string) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Method != "POST" || r.URL.Path != "/api/oauth2" || r.Header.Get("X_API_VERSION") != "1.5" {
+			http.Error(w, "unexpected request", http.StatusNotFound)
+			return
+		}
+		if location := redirect(); location != "" {
+			http.Redirect(w, r, location+"/api/oauth2", http.StatusFound)
+			return
+		}
+		//the token request has no form content type so the body is parsed as it is
+		body, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(body))
+		if form.Get("grant_type") != "refresh_token" || form.Get("refresh_token") != "good" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"abc","expires_in":7200,"token_type":"bearer"}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// noRedirect is the redirect func of a shard which issues tokens itself
+func noRedirect() string { return "" }
+
+func TestDiscoverEndpointFollowsShardRedirects(t *testing.T) {
+	home, _ := newShard(t, noRedirect)
+	for _, status := range []int{http.StatusMovedPermanently, http.StatusFound} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, home.URL+"/api/oauth2", status)
+			}))
+			defer other.Close()
+
+			endPoint, token, err := DiscoverEndpoint("good", other.URL)
+			if err != nil {
+				t.Fatalf("DiscoverEndpoint() error = %s", err)
+			}
+			if endPoint != home.URL || token != "Bearer abc" {
+				t.Errorf("DiscoverEndpoint() = %s, %s, want %s, Bearer abc", endPoint, token, home.URL)
+			}
+		})
+	}
+}
+
+func TestDiscoverEndpointProbesCandidates(t *testing.T) {
+	home, _ := newShard(t, noRedirect)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	defer func(known []string) { KnownEndpoints = known }(KnownEndpoints)
+	KnownEndpoints = []string{down.URL, home.URL}
+	endPoint, _, err := DiscoverEndpoint("good")
+	if err != nil || endPoint != home.URL {
+		t.Fatalf("DiscoverEndpoint() = %s, %v, want the second known endpoint %s", endPoint, err, home.URL)
+	}
+}
+
+func TestDiscoverEndpointErrors(t *testing.T) {
+	home, homeCalls := newShard(t, noRedirect)
+	var loop *httptest.Server
+	loop, _ = newShard(t, func() string { return loop.URL })
+
+	t.Run("bad refresh token", func(t *testing.T) {
+		_, _, err := DiscoverEndpoint("bad", home.URL)
+		if err == nil || !strings.Contains(err.Error(), "expected 200 got 400") || !strings.Contains(err.Error(), "invalid_grant") {
+			t.Errorf("DiscoverEndpoint() error = %v, want the 400 and its body", err)
+		}
+	})
+
+	t.Run("known endpoints exhausted", func(t *testing.T) {
+		before := atomic.LoadInt32(homeCalls)
+		_, _, err := DiscoverEndpoint("bad", home.URL, home.URL)
+		if err == nil || strings.Count(err.Error(), home.URL+":") != 2 {
+			t.Errorf("DiscoverEndpoint() error = %v, want an error for each candidate", err)
+		}
+		if calls := atomic.LoadInt32(homeCalls) - before; calls != 2 {
+			t.Errorf("%d token requests, want one per candidate", calls)
+		}
+	})
+
+	t.Run("redirect loop", func(t *testing.T) {
+		_, _, err := DiscoverEndpoint("good", loop.URL)
+		if err == nil || !strings.Contains(err.Error(), "too many shard redirects") {
+			t.Errorf("DiscoverEndpoint() error = %v, want too many shard redirects", err)
+		}
+	})
+
+	t.Run("redirect without a location", func(t *testing.T) {
+		bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusFound)
+		}))
+		defer bad.Close()
+		_, _, err := DiscoverEndpoint("good", bad.URL)
+		if err == nil || !strings.Contains(err.Error(), "without a location") {
+			t.Errorf("DiscoverEndpoint() error = %v, want a missing location error", err)
+		}
+	})
+}
+
+func TestGetBearerTokenDoesNotFollowRedirects(t *testing.T) {
+	home, homeCalls := newShard(t, noRedirect)
+	other, _ := newShard(t, func() string { return home.URL })
+
+	token, err := getBearerToken("good", home.URL)
+	if err != nil || token != "Bearer abc" {
+		t.Fatalf("getBearerToken() = %s, %v, want Bearer abc", token, err)
+	}
+	before := atomic.LoadInt32(homeCalls)
+	_, err = getBearerToken("good", other.URL)
+	if err == nil || !strings.Contains(err.Error(), "wrong endpoint "+other.URL) || !strings.Contains(err.Error(), "belongs to "+home.URL) {
+		t.Errorf("getBearerToken() error = %v, want the shard the token belongs to", err)
+	}
+	if calls := atomic.LoadInt32(homeCalls) - before; calls != 0 {
+		t.Errorf("the redirect was followed %d times", calls)
+	}
+}
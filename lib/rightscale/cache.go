@@ -0,0 +1,183 @@
+package rightscale
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheOptions configures the optional response cache, see Client.WithCache
+type CacheOptions struct {
+	// DefaultTTL is how long a response is served from the cache when its resource has no entry in TTLs
+	DefaultTTL time.Duration
+	// TTLs overrides DefaultTTL per resource, keyed by the resource's collection name
+	// e.g. deployments, server_arrays, current_instances, server_templates, inputs
+	TTLs map[string]time.Duration
+}
+
+// responseCache is a TTL cache of GET responses keyed by account and URL
+// expired entries are kept so they can be revalidated with their ETag
+type responseCache struct {
+	mu      sync.Mutex
+	opts    CacheOptions
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a single cached response
+type cacheEntry struct {
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+// instanceActions are the actions which change the instances of an array and so its instance counts
+// every other action Rightscale has that the library calls is named multi_something
+var instanceActions = map[string]bool{"launch": true, "terminate": true, "multi_terminate": true}
+
+// WithCache returns a copy of the client which caches GET responses made through Request
+// Fresh responses are served from memory, expired ones are revalidated with If-None-Match when Rightscale
+// supplied an ETag. Mutating calls made by the client invalidate the entries they affect. Copies of the
+// returned client, including those from ForAccount, share the cache
+func (c Client) WithCache(opts CacheOptions) Client {
+	c.cache = &responseCache{opts: opts, entries: map[string]cacheEntry{}}
+	return c
+}
+
+// Uncached returns a copy of the client whose GET requests always go to Rightscale, cached responses are still
+// revalidated by ETag and refreshed. Polls and checks following a mutation use it so they see the change
+func (c Client) Uncached() Client {
+	c.uncached = true
+	return c
+}
+
+// InvalidateCache drops every cached response, it is a noop for clients without a cache
+func (c Client) InvalidateCache() {
+	if c.cache == nil {
+		return
+	}
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	c.cache.entries = map[string]cacheEntry{}
+}
+
+// cacheKey identifies a request in the cache, responses differ per account so it is part of the key
+func (c Client) cacheKey(path string) string {
+	return c.Account + " " + path
+}
+
+// lookup returns the cached entry for key and whether it is still fresh
+func (rc *responseCache) lookup(key string) (cacheEntry, bool) {
+	if rc == nil {
+		return cacheEntry{}, false
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	entry, ok := rc.entries[key]
+	return entry, ok && time.Now().Before(entry.expires)
+}
+
+// store caches a response body for its resource's TTL
+func (rc *responseCache) store(key string, body []byte, etag string) {
+	if rc == nil {
+		return
+	}
+	ttl := rc.ttl(key)
+	if ttl <= 0 && etag == "" {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entries[key] = cacheEntry{body: body, etag: etag, expires: time.Now().Add(ttl)}
+}
+
+// refresh extends the life of an entry which Rightscale confirmed is unchanged
+func (rc *responseCache) refresh(key string) {
+	if rc == nil {
+		return
+	}
+	ttl := rc.ttl(key)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if entry, ok := rc.entries[key]; ok {
+		entry.expires = time.Now().Add(ttl)
+		rc.entries[key] = entry
+	}
+}
+
+// ttl returns how long a response for key stays fresh
+func (rc *responseCache) ttl(key string) time.Duration {
+	collection, _ := resourcePath(key)
+	if ttl, ok := rc.opts.TTLs[collection]; ok {
+		return ttl
+	}
+	return rc.opts.DefaultTTL
+}
+
+// invalidate drops the entries a mutating request to path affects. That is the resource itself and anything
+// nested below it, every listing of the same collection and, for launch and terminate, every instance listing
+// and array since their instance counts change
+func (rc *responseCache) invalidate(path string) {
+	if rc == nil {
+		return
+	}
+	collection, action := resourcePath(path)
+	href := resourceHref(path)
+	if action != "" {
+		href = strings.TrimSuffix(href, "/"+action)
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for key := range rc.entries {
+		keyCollection, _ := resourcePath(key)
+		keyHref := resourceHref(key)
+		switch {
+		case keyHref == href || strings.HasPrefix(keyHref, href+"/"):
+		case keyCollection == collection:
+		case instanceActions[action] && (strings.HasSuffix(keyCollection, "instances") || keyCollection == "server_arrays"):
+		default:
+			continue
+		}
+		delete(rc.entries, key)
+	}
+}
+
+// invalidateArray drops every cached response for an array and its listings, for calls which change an array
+// through another resource such as its next instance
+func (c Client) invalidateArray(array ServerArray) {
+	if href := array.id(); href != "" {
+		c.cache.invalidate(href)
+	}
+}
+
+// resourceHref strips the account, endpoint and query from a cache key or url leaving the api path
+func resourceHref(key string) string {
+	if i := strings.Index(key, " "); i >= 0 {
+		key = key[i+1:]
+	}
+	if u, err := url.Parse(key); err == nil {
+		return u.Path
+	}
+	return key
+}
+
+// resourcePath returns the collection a path refers to and, when the path ends in an action such as launch,
+// the action. Rightscale paths alternate collection and id after /api/ e.g. /api/clouds/1/instances/ABC,
+// so the collection is the last segment in a collection position once any action is removed
+func resourcePath(key string) (collection string, action string) {
+	segments := strings.Split(strings.Trim(resourceHref(key), "/"), "/")
+	if len(segments) > 0 && segments[0] == "api" {
+		segments = segments[1:]
+	}
+	if len(segments) == 0 {
+		return "", ""
+	}
+	if last := segments[len(segments)-1]; len(segments) > 1 && (instanceActions[last] || strings.HasPrefix(last, "multi_")) {
+		action = last
+		segments = segments[:len(segments)-1]
+	}
+	for i := 0; i < len(segments); i += 2 {
+		collection = segments[i]
+	}
+	return collection, action
+}
@@ -0,0 +1,164 @@
+package rightscale
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResourcePath(t *testing.T) {
+	tests := []struct {
+		key        string
+		collection string
+		action     string
+	}{
+		{key: "/api/deployments", collection: "deployments"},
+		{key: "12 /api/server_arrays/10?view=instance_detail", collection: "server_arrays"},
+		{key: "/api/server_arrays/10/current_instances", collection: "current_instances"},
+		{key: "/api/clouds/1/instances/ABC", collection: "instances"},
+		{key: "/api/server_arrays/10/launch?count=2", collection: "server_arrays", action: "launch"},
+		{key: "/api/clouds/1/instances/ABC/terminate", collection: "instances", action: "terminate"},
+		{key: "/api/tags/multi_add", collection: "tags", action: "multi_add"},
+	}
+	for _, tt := range tests {
+		collection, action := resourcePath(tt.key)
+		if collection != tt.collection || action != tt.action {
+			t.Errorf("resourcePath(%q) = %q, %q, want %q, %q", tt.key, collection, action, tt.collection, tt.action)
+		}
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	keys := []string{
+		" /api/deployments",
+		" /api/deployments/1/server_arrays?view=instance_detail",
+		" /api/server_arrays/10?view=instance_detail",
+		" /api/server_arrays/10/current_instances",
+		" /api/clouds/1/instances/NEXT/inputs",
+		" /api/server_templates/5",
+	}
+	tests := []struct {
+		path string
+		kept []string
+	}{
+		{
+			path: "/api/server_arrays/10",
+			kept: []string{" /api/clouds/1/instances/NEXT/inputs", " /api/deployments", " /api/server_templates/5"},
+		},
+		{
+			path: "/api/server_arrays/10/launch",
+			kept: []string{" /api/clouds/1/instances/NEXT/inputs", " /api/deployments", " /api/server_templates/5"},
+		},
+		{
+			path: "/api/clouds/1/instances/NEXT/inputs/multi_update",
+			kept: []string{" /api/deployments", " /api/deployments/1/server_arrays?view=instance_detail",
+				" /api/server_arrays/10/current_instances", " /api/server_arrays/10?view=instance_detail", " /api/server_templates/5"},
+		},
+		{
+			path: "/api/deployments/1",
+			kept: []string{" /api/clouds/1/instances/NEXT/inputs", " /api/server_arrays/10/current_instances",
+				" /api/server_arrays/10?view=instance_detail", " /api/server_templates/5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			c := Client{}.WithCache(CacheOptions{DefaultTTL: time.Hour})
+			for _, key := range keys {
+				c.cache.store(key, []byte("[]"), "")
+			}
+			c.cache.invalidate(tt.path)
+			var kept []string
+			for key := range c.cache.entries {
+				kept = append(kept, key)
+			}
+			sort.Strings(kept)
+			if strings.Join(kept, "\n") != strings.Join(tt.kept, "\n") {
+				t.Errorf("kept %q, want %q", kept, tt.kept)
+			}
+		})
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := Client{}.WithCache(CacheOptions{DefaultTTL: time.Hour, TTLs: map[string]time.Duration{"current_instances": -1}})
+	c.cache.store(" /api/deployments", []byte("[]"), "")
+	c.cache.store(" /api/server_arrays/10/current_instances", []byte("[]"), "")
+	c.cache.store(" /api/server_arrays/11/current_instances", []byte("[]"), `"etag"`)
+	if _, fresh := c.cache.lookup(" /api/deployments"); !fresh {
+		t.Error("deployments are not fresh within their TTL")
+	}
+	if _, ok := c.cache.entries[" /api/server_arrays/10/current_instances"]; ok {
+		t.Error("a response with no TTL and no ETag was cached")
+	}
+	entry, fresh := c.cache.lookup(" /api/server_arrays/11/current_instances")
+	if fresh || entry.etag != `"etag"` {
+		t.Errorf("lookup = %+v, %t, want a stale entry kept for revalidation", entry, fresh)
+	}
+}
+
+func TestArrayTemplateAfterUpdate(t *testing.T) {
+	api, c := newFakeAPI(t)
+	c = c.WithCache(CacheOptions{DefaultTTL: time.Hour})
+	api.on("GET", "/api/server_arrays/10?view=instance_detail", 200, arrayJSON)
+	api.on("GET", "/api/server_templates/5", 200, `{"name":"web","revision":3}`)
+	api.on("GET", "/api/server_templates/6", 200, `{"name":"web","revision":4}`)
+	api.on("PUT", "/api/clouds/1/instances/NEXT", 204, "")
+
+	for n := 0; n < 2; n++ {
+		template, err := c.ArrayTemplate("10")
+		if err != nil || template.Revision != 3 {
+			t.Fatalf("ArrayTemplate() = %+v, %v, want revision 3", template, err)
+		}
+	}
+	if n := api.count("GET", "/api/server_arrays/10?view=instance_detail"); n != 1 {
+		t.Errorf("array requested %d times, want the second read served from the cache", n)
+	}
+
+	array := ServerArray{Links: rsLinks{{Rel: "self", Href: "/api/server_arrays/10"},
+		{Rel: "next_instance", Href: "/api/clouds/1/instances/NEXT"}}}
+	desired := ServerTemplate{Name: "web", Revision: 4, Links: rsLinks{{Rel: "self", Href: "/api/server_templates/6"}}}
+	api.on("GET", "/api/server_arrays/10?view=instance_detail", 200,
+		strings.Replace(arrayJSON, "/api/server_templates/5", "/api/server_templates/6", 1))
+	if err := c.UpdateArrayTemplate(array, desired); err != nil {
+		t.Fatalf("UpdateArrayTemplate() error = %s", err)
+	}
+	template, err := c.ArrayTemplate("10")
+	if err != nil || template.Revision != 4 {
+		t.Fatalf("ArrayTemplate() after update = %+v, %v, want revision 4", template, err)
+	}
+}
+
+func TestWaitBypassesCache(t *testing.T) {
+	api, c := newFakeAPI(t)
+	c = c.WithCache(CacheOptions{DefaultTTL: time.Hour})
+	api.onSequence("GET", "/api/server_arrays/10/current_instances", 200,
+		`[{"name":"i1","state":"booting"}]`, `[{"name":"i1","state":"operational"}]`)
+	api.onSequence("GET", "/api/clouds/1/instances/ABC", 200,
+		`{"name":"i1","state":"booting"}`, `{"name":"i1","state":"operational"}`)
+	//prime the cache with the first answers, a cached wait would never see the second
+	if _, err := c.GetArrayInstances("10"); err != nil {
+		t.Fatalf("GetArrayInstances() error = %s", err)
+	}
+	if _, err := c.Instance("1", "ABC"); err != nil {
+		t.Fatalf("Instance() error = %s", err)
+	}
+
+	opts := WaitOptions{Timeout: time.Second, InitialInterval: 5 * time.Millisecond}
+	if _, err := c.WaitForArrayCount(context.Background(), "10", 1, opts); err != nil {
+		t.Errorf("WaitForArrayCount() error = %s", err)
+	}
+	if _, err := c.WaitForInstanceState(context.Background(), "1", "ABC", "operational", opts); err != nil {
+		t.Errorf("WaitForInstanceState() error = %s", err)
+	}
+
+	//the waits refreshed the cache, so plain reads now see the new state too
+	instances, err := c.GetArrayInstances("10")
+	if err != nil || len(instances) != 1 || instances[0].State != "operational" {
+		t.Errorf("GetArrayInstances() after wait = %+v, %v, want the refreshed listing", instances, err)
+	}
+	if n := api.count("GET", "/api/server_arrays/10/current_instances"); n != 2 {
+		t.Errorf("instances requested %d times, want the read after the wait served from the cache", n)
+	}
+}
@@ -1,10 +1,11 @@
 package rightscale
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/pkg/errors"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"strings"
 )
@@ -21,11 +22,23 @@ type Client struct {
 	RefreshToken string
 	EndPoint     string
 	BearerToken  string
+	// Account is the numeric ID of the account requests are made against, empty means the token's own account
+	Account   string
+	preflight *preflight
+	cache     *responseCache
+	logger    Logger
+	hooks     Hooks
+	ctx       context.Context
+	uncached  bool
 }
 
 // New is the entry point into Rightscale lib. returns a fresh Rightscale clinet object which is capable of making needed requests
+// When endpoint is empty the token's shard is discovered with DiscoverEndpoint, the resolved endpoint is in Client.EndPoint
 // todo, think about not exporting client - https://stackoverflow.com/questions/37135193/how-to-set-default-values-in-golang-structs
 func New(refreshToken string, endpoint string) (c Client, e error) {
+	if endpoint == "" {
+		return NewWithDiscovery(refreshToken)
+	}
 	c.EndPoint = endpoint
 	c.RefreshToken = refreshToken
 	bt, err := getBearerToken(refreshToken, endpoint)
@@ -37,14 +50,35 @@ func New(refreshToken string, endpoint string) (c Client, e error) {
 	return
 }
 
+// NewWithDiscovery builds a client without knowing the token's shard, see DiscoverEndpoint
+// candidates are the endpoints to probe, KnownEndpoints are used when none are given
+func NewWithDiscovery(refreshToken string, candidates ...string) (c Client, e error) {
+	endpoint, bt, err := DiscoverEndpoint(refreshToken, candidates...)
+	if err != nil {
+		return Client{}, errors.Errorf("encountered issue building client %s", err)
+	}
+	c.EndPoint = endpoint
+	c.RefreshToken = refreshToken
+	c.BearerToken = bt
+	return
+}
+
 // Request takes a prebuilt param object and executes the needed API call as provide by the RequestParams struct
 func (c Client) Request(RequestParams RequestParams) ([]byte, error) {
+	key := c.cacheKey(RequestParams.url)
+	if RequestParams.method != "GET" {
+		defer c.cache.invalidate(RequestParams.url)
+	}
+	cached, fresh := c.cache.lookup(key)
+	if RequestParams.method == "GET" && fresh && !c.uncached {
+		return cached.body, nil
+	}
 	client := http.Client{}
 	url := strings.Join([]string{c.EndPoint, RequestParams.url}, "")
-	req, err := http.NewRequest(RequestParams.method, url, nil)
+	req, err := http.NewRequestWithContext(c.requestContext(), RequestParams.method, url, nil)
 	if RequestParams.body != nil {
 		j, _ := json.Marshal(RequestParams.body)
-		req, err = http.NewRequest(RequestParams.method, url, strings.NewReader(string(j)))
+		req, err = http.NewRequestWithContext(c.requestContext(), RequestParams.method, url, strings.NewReader(string(j)))
 		if err != nil {
 			return []byte{}, errors.Errorf("an error was encountered while building request with body %s", err)
 		}
@@ -54,19 +88,30 @@ func (c Client) Request(RequestParams RequestParams) ([]byte, error) {
 		return []byte{}, errors.Errorf("an error was encountered while building request %s", err)
 	}
 
-	req.Header.Add("X_API_VERSION", "1.5")
-	req.Header.Add("Authorization", c.BearerToken)
-	req.Header.Add("Content-type", "application/json")
+	c.setHeaders(req)
+	if RequestParams.method == "GET" && cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	info := c.beforeRequest(RequestParams.method, url)
 	response, err := client.Do(req)
 
 	if err != nil {
+		c.afterRequest(info, 0, err)
 		return []byte{}, errors.Errorf("An error was encountered while performing request to RS %s", err)
 	}
 	defer response.Body.Close()
+	defer c.afterRequest(info, response.StatusCode, nil)
+	if response.StatusCode == http.StatusNotModified && cached.etag != "" {
+		c.cache.refresh(key)
+		return cached.body, nil
+	}
 	responseBody, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		return []byte{}, errors.Errorf("an error was encountered reading response data from RS request %s", err)
 	}
+	if RequestParams.method == "GET" && response.StatusCode == http.StatusOK {
+		c.cache.store(key, responseBody, response.Header.Get("ETag"))
+	}
 	//fmt.Printf("%v",string(responseBody)) Print raw response JSON
 	return responseBody, nil
 }
@@ -74,13 +119,16 @@ func (c Client) Request(RequestParams RequestParams) ([]byte, error) {
 // RequestDetailed takes a prebuilt param object and executes the needed API call as provide by the RequestParams struct
 // this function is different from Request in that it returns the full http. Response object for further processing
 func (c Client) RequestDetailed(RequestParams RequestParams) (*http.Response, error) {
+	if RequestParams.method != "GET" {
+		defer c.cache.invalidate(RequestParams.url)
+	}
 	client := http.Client{}
 	url := strings.Join([]string{c.EndPoint, RequestParams.url}, "")
-	log.Println("Request URL:", url)
-	req, err := http.NewRequest(RequestParams.method, url, nil)
+	c.log().Debug("rightscale request", "method", RequestParams.method, "url", url)
+	req, err := http.NewRequestWithContext(c.requestContext(), RequestParams.method, url, nil)
 	if RequestParams.body != nil {
 		j, _ := json.Marshal(RequestParams.body)
-		req, err = http.NewRequest(RequestParams.method, url, strings.NewReader(string(j)))
+		req, err = http.NewRequestWithContext(c.requestContext(), RequestParams.method, url, strings.NewReader(string(j)))
 		if err != nil {
 			return nil, errors.Errorf("an error was encountered while building request with body %s", err)
 		}
@@ -90,16 +138,47 @@ func (c Client) RequestDetailed(RequestParams RequestParams) (*http.Response, er
 		return nil, errors.Errorf("an error was encountered while building request %s", err)
 	}
 
-	req.Header.Add("X_API_VERSION", "1.5")
-	req.Header.Add("Authorization", c.BearerToken)
-	req.Header.Add("Content-type", "application/json")
+	c.setHeaders(req)
+	info := c.beforeRequest(RequestParams.method, url)
 	response, err := client.Do(req)
 
 	if err != nil {
+		c.afterRequest(info, 0, err)
 		return nil, errors.Errorf("An error was encountered while performing request to RS %s", err)
 	}
 	defer response.Body.Close()
+	defer c.afterRequest(info, response.StatusCode, nil)
+	//the body is buffered so callers can still read it after the connection is closed
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Errorf("an error was encountered reading response data from RS request %s", err)
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
 	return response, nil
 }
 
+// WithContext returns a copy of the client whose requests are cancelled when ctx is done
+func (c Client) WithContext(ctx context.Context) Client {
+	c.ctx = ctx
+	return c
+}
+
+// requestContext returns the context requests are made with, see WithContext
+func (c Client) requestContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// setHeaders adds the headers every Rightscale API request needs
+func (c Client) setHeaders(req *http.Request) {
+	req.Header.Add("X_API_VERSION", "1.5")
+	req.Header.Add("Authorization", c.BearerToken)
+	req.Header.Add("Content-type", "application/json")
+	if c.Account != "" {
+		req.Header.Add("X-Account", c.Account)
+	}
+}
+
 //todo refactor Request function back down to single function
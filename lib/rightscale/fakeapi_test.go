@@ -0,0 +1,114 @@
+package rightscale
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeAPI serves canned Rightscale responses keyed by method and request uri
+// responses registered for an account are only served to requests carrying that X-Account header
+type fakeAPI struct {
+	mu       sync.Mutex
+	routes   map[string]fakeResponse
+	requests []string
+	sent     map[string][]string
+}
+
+// fakeResponse is served in turn with its bodies, the last body is repeated once the others are used up
+type fakeResponse struct {
+	status int
+	bodies []string
+}
+
+// newFakeAPI starts a fake Rightscale API and returns a client pointed at it
+func newFakeAPI(t *testing.T) (*fakeAPI, Client) {
+	t.Helper()
+	f := &fakeAPI{routes: map[string]fakeResponse{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, Client{EndPoint: server.URL}
+}
+
+// on registers the response to a request made without an account
+func (f *fakeAPI) on(method, uri string, status int, body string) {
+	f.onAccount("", method, uri, status, body)
+}
+
+// onAccount registers the response to a request made against an account
+func (f *fakeAPI) onAccount(account, method, uri string, status int, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.routes[account+" "+method+" "+uri] = fakeResponse{status: status, bodies: []string{body}}
+}
+
+// onSequence registers responses served one per request in order, the last is repeated from then on
+func (f *fakeAPI) onSequence(method, uri string, status int, bodies ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.routes[" "+method+" "+uri] = fakeResponse{status: status, bodies: bodies}
+}
+
+// count returns how many requests were made for method and uri
+func (f *fakeAPI) count(method, uri string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.requests {
+		if r == method+" "+uri {
+			n++
+		}
+	}
+	return n
+}
+
+// bodies returns the request bodies sent for method and uri in the order they were made
+func (f *fakeAPI) bodies(method, uri string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sent[method+" "+uri]
+}
+
+// ServeHTTP implements http.Handler, unknown requests get a 404
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+	if len(body) > 0 {
+		if f.sent == nil {
+			f.sent = map[string][]string{}
+		}
+		f.sent[r.Method+" "+r.URL.RequestURI()] = append(f.sent[r.Method+" "+r.URL.RequestURI()], string(body))
+	}
+	key := r.Header.Get("X-Account") + " " + r.Method + " " + r.URL.RequestURI()
+	resp, ok := f.routes[key]
+	if ok && len(resp.bodies) > 1 {
+		f.routes[key] = fakeResponse{status: resp.status, bodies: resp.bodies[1:]}
+	}
+	f.mu.Unlock()
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.status)
+	w.Write([]byte(resp.bodies[0]))
+}
+
+// deploymentJSON and arrayJSON build minimal resource bodies for the fake API
+const deploymentJSON = `{"name":"d1","links":[{"rel":"self","href":"/api/deployments/1"},` +
+	`{"rel":"server_arrays","href":"/api/deployments/1/server_arrays"}]}`
+
+const arrayJSON = `{"name":"a1","state":"enabled","instances_count":2,` +
+	`"elasticity_params":{"bounds":{"min_count":"1","max_count":"3"}},` +
+	`"next_instance":{"links":[{"rel":"server_template","href":"/api/server_templates/5"}]},` +
+	`"links":[{"rel":"self","href":"/api/server_arrays/10"},{"rel":"deployment","href":"/api/deployments/1"},` +
+	`{"rel":"next_instance","href":"/api/clouds/1/instances/NEXT"}]}`
+
+// serveDeployment registers one deployment containing array a1 for account ("" for none)
+func (f *fakeAPI) serveDeployment(account string) {
+	f.onAccount(account, "GET", "/api/deployments", 200, "["+deploymentJSON+"]")
+	f.onAccount(account, "GET", "/api/deployments/1/server_arrays?view=instance_detail", 200, "["+arrayJSON+"]")
+}
@@ -0,0 +1,109 @@
+package rightscale
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RequestInfo describes an HTTP request the client is about to make
+// Endpoint is the path with ids replaced by :id e.g. /api/server_arrays/:id, which keeps metric labels bounded
+type RequestInfo struct {
+	Method   string
+	URL      string
+	Endpoint string
+	Start    time.Time
+}
+
+// ResponseInfo describes the outcome of an HTTP request, StatusCode is 0 when Err is a network error
+type ResponseInfo struct {
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// Hooks are called around every HTTP request the client makes, including the OAuth call of NewWithHooks
+// Hooks are called from whichever goroutine makes the request so implementations must be safe for concurrent use
+type Hooks interface {
+	BeforeRequest(req RequestInfo)
+	AfterRequest(req RequestInfo, resp ResponseInfo)
+}
+
+// WithHooks returns a copy of the client which calls hooks around every HTTP request
+// Responses served from the cache are not HTTP requests and do not call hooks
+func (c Client) WithHooks(hooks Hooks) Client {
+	c.hooks = hooks
+	return c
+}
+
+// NewWithHooks is New with hooks installed before the OAuth call is made, so the auth request is instrumented too
+// As with New an empty endpoint is discovered, every probe of the discovery calls the hooks
+func NewWithHooks(refreshToken string, endpoint string, hooks Hooks) (c Client, e error) {
+	c.RefreshToken = refreshToken
+	c.hooks = hooks
+	if endpoint == "" {
+		endpoint, c.BearerToken, e = c.discoverEndpoint(refreshToken)
+		if e != nil {
+			return Client{}, errors.Errorf("encountered issue building client %s", e)
+		}
+		c.EndPoint = endpoint
+		return
+	}
+	c.EndPoint = endpoint
+	bt, err := c.authenticate(refreshToken, endpoint)
+	if err != nil {
+		return Client{}, errors.Errorf("encountered issue building client %s", err)
+	}
+	c.BearerToken = bt
+	return
+}
+
+// beforeRequest notifies the client's hooks that a request is starting and returns its description
+func (c Client) beforeRequest(method string, fullURL string) RequestInfo {
+	info := RequestInfo{Method: method, URL: fullURL, Endpoint: endpointTemplate(fullURL), Start: time.Now()}
+	if c.hooks != nil {
+		c.hooks.BeforeRequest(info)
+	}
+	return info
+}
+
+// afterRequest notifies the client's hooks that a request has finished
+func (c Client) afterRequest(info RequestInfo, statusCode int, err error) {
+	if c.hooks != nil {
+		c.hooks.AfterRequest(info, ResponseInfo{StatusCode: statusCode, Duration: time.Since(info.Start), Err: err})
+	}
+}
+
+// endpointTemplate replaces the ids in a Rightscale url with :id and drops the host and query
+// e.g. https://us-3.rightscale.com/api/clouds/1/instances/ABC/terminate becomes /api/clouds/:id/instances/:id/terminate
+// while /api/sessions/accounts and /api/tags/by_resource are left as they are
+func endpointTemplate(fullURL string) string {
+	path := fullURL
+	if u, err := url.Parse(fullURL); err == nil {
+		path = u.Path
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if isResourceID(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// isResourceID reports whether a path segment is an id rather than a collection or action
+// Rightscale ids are numeric or upper case resource ids e.g. 12345 or 5ABC2DEF0GH1, collections and actions
+// are lower case words e.g. server_arrays, by_resource
+func isResourceID(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	for _, r := range segment {
+		if !(r >= '0' && r <= '9' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
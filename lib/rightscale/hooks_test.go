@@ -0,0 +1,61 @@
+package rightscale
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestEndpointTemplate(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://us-3.rightscale.com/api/clouds/1/instances/ABC12DEF/terminate", want: "/api/clouds/:id/instances/:id/terminate"},
+		{url: "https://us-3.rightscale.com/api/server_arrays/10?view=instance_detail", want: "/api/server_arrays/:id"},
+		{url: "https://us-3.rightscale.com/api/server_arrays/10/current_instances", want: "/api/server_arrays/:id/current_instances"},
+		{url: "https://us-3.rightscale.com/api/sessions/accounts", want: "/api/sessions/accounts"},
+		{url: "https://us-3.rightscale.com/api/tags/by_resource", want: "/api/tags/by_resource"},
+		{url: "https://us-3.rightscale.com/api/tags/multi_add", want: "/api/tags/multi_add"},
+		{url: "https://us-3.rightscale.com/api/deployments", want: "/api/deployments"},
+		{url: "https://us-3.rightscale.com/api/oauth2", want: "/api/oauth2"},
+	}
+	for _, tt := range tests {
+		if got := endpointTemplate(tt.url); got != tt.want {
+			t.Errorf("endpointTemplate(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+// recordingHooks keeps the endpoint of every request it sees
+type recordingHooks struct {
+	mu        sync.Mutex
+	endpoints []string
+}
+
+func (h *recordingHooks) BeforeRequest(req RequestInfo) {}
+
+func (h *recordingHooks) AfterRequest(req RequestInfo, resp ResponseInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.endpoints = append(h.endpoints, req.Method+" "+req.Endpoint)
+}
+
+func TestNewWithHooksDiscoversEndpoint(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.on("POST", "/api/oauth2", 200, `{"access_token":"abc"}`)
+	known := KnownEndpoints
+	KnownEndpoints = []string{c.EndPoint}
+	defer func() { KnownEndpoints = known }()
+
+	hooks := &recordingHooks{}
+	client, err := NewWithHooks("token", "", hooks)
+	if err != nil {
+		t.Fatalf("NewWithHooks() error = %s", err)
+	}
+	if client.EndPoint != c.EndPoint || client.BearerToken != "Bearer abc" {
+		t.Errorf("client = %s %q, want %s with a bearer token", client.EndPoint, client.BearerToken, c.EndPoint)
+	}
+	if len(hooks.endpoints) != 1 || hooks.endpoints[0] != "POST /api/oauth2" {
+		t.Errorf("hooks saw %q, want the discovery request", hooks.endpoints)
+	}
+}
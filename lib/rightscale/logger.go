@@ -0,0 +1,33 @@
+package rightscale
+
+// Logger receives the library's log messages. fields are alternating key/value pairs
+// The method set matches *slog.Logger so one can be passed straight to WithLogger
+type Logger interface {
+	Debug(msg string, fields ...interface{})
+	Info(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+}
+
+// nopLogger discards everything, it is used when a client has no logger
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields ...interface{}) {}
+func (nopLogger) Info(msg string, fields ...interface{})  {}
+func (nopLogger) Warn(msg string, fields ...interface{})  {}
+func (nopLogger) Error(msg string, fields ...interface{}) {}
+
+// WithLogger returns a copy of the client which sends its log messages to logger
+// Clients are silent by default
+func (c Client) WithLogger(logger Logger) Client {
+	c.logger = logger
+	return c
+}
+
+// log returns the client's logger, or one which discards everything when none was set
+func (c Client) log() Logger {
+	if c.logger == nil {
+		return nopLogger{}
+	}
+	return c.logger
+}
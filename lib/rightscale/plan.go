@@ -0,0 +1,327 @@
+package rightscale
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// DesiredState describes how a set of server arrays should look, it is loaded from a YAML or JSON spec
+type DesiredState struct {
+	Arrays []ArraySpec `json:"arrays" yaml:"arrays"`
+}
+
+// ArraySpec describes the desired state of a single server array
+// Arrays are matched by Name within the named Deployment. Only the attributes present in the spec are managed,
+// e.g. leaving Schedule empty leaves the array's schedule alone and tags not listed are never removed.
+// Input values use Rightscale's kind:value form e.g. text:foo
+type ArraySpec struct {
+	Deployment       string            `json:"deployment" yaml:"deployment"`
+	Name             string            `json:"name" yaml:"name"`
+	Template         string            `json:"template" yaml:"template"`
+	TemplateRevision *int              `json:"template_revision" yaml:"template_revision"`
+	MinCount         *int              `json:"min_count" yaml:"min_count"`
+	MaxCount         *int              `json:"max_count" yaml:"max_count"`
+	Schedule         []ScheduleEntry   `json:"schedule" yaml:"schedule"`
+	Inputs           map[string]string `json:"inputs" yaml:"inputs"`
+	Tags             map[string]string `json:"tags" yaml:"tags"`
+}
+
+// PlanStep is a single change needed to bring an array to its desired state
+type PlanStep struct {
+	Array      string
+	Deployment string
+	Field      string
+	From       string
+	To         string
+	apply      func(c Client) error
+}
+
+// Plan is the ordered list of changes needed to bring the account to a desired state
+// it implements the report.Table interface
+type Plan []PlanStep
+
+// LoadDesiredState reads a desired state spec from disk, the format is picked from the file extension
+// .yaml, .yml and .json are supported
+func LoadDesiredState(path string) (DesiredState, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return DesiredState{}, errors.Errorf("could not read desired state file %s - %s", path, err)
+	}
+	return ParseDesiredState(data, strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// ParseDesiredState parses a desired state spec in the given format, either yaml, yml or json
+func ParseDesiredState(data []byte, format string) (state DesiredState, e error) {
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		e = yaml.UnmarshalStrict(data, &state)
+	case "json":
+		//unknown fields are rejected like they are in yaml so a typo is not silently ignored
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		e = decoder.Decode(&state)
+	default:
+		return DesiredState{}, errors.Errorf("unsupported desired state format %q", format)
+	}
+	if e != nil {
+		return DesiredState{}, errors.Errorf("could not parse desired state %s", e)
+	}
+	for i, spec := range state.Arrays {
+		if spec.Deployment == "" || spec.Name == "" {
+			return DesiredState{}, errors.Errorf("array spec %d requires a deployment and a name", i)
+		}
+		if (spec.Template == "") != (spec.TemplateRevision == nil) {
+			return DesiredState{}, errors.Errorf("array spec %s requires both template and template_revision", spec.Name)
+		}
+		for _, entry := range spec.Schedule {
+			if _, _, _, err := entry.clock(); err != nil {
+				return DesiredState{}, errors.Errorf("array spec %s has an invalid schedule - %s", spec.Name, err)
+			}
+		}
+	}
+	return state, nil
+}
+
+// Plan compares the desired state against the live account and returns the changes needed
+// An error is returned when a deployment or array in the spec does not exist, creating them is not supported
+func (c Client) Plan(state DesiredState) (Plan, error) {
+	deploymentList, err := c.GetDeployments()
+	if err != nil {
+		return nil, err
+	}
+	wantTags := false
+	for _, spec := range state.Arrays {
+		if len(spec.Tags) > 0 {
+			wantTags = true
+		}
+	}
+	arrayList, err := c.Arrays(wantTags)
+	if err != nil {
+		return nil, err
+	}
+	var plan Plan
+	for _, spec := range state.Arrays {
+		array, err := findSpecArray(spec, deploymentList, arrayList)
+		if err != nil {
+			return nil, err
+		}
+		steps, err := c.planArray(spec, array)
+		if err != nil {
+			return nil, errors.Errorf("could not plan array %s - %s", spec.Name, err)
+		}
+		plan = append(plan, steps...)
+	}
+	return plan, nil
+}
+
+// Apply runs each step of the plan in order and stops at the first failure
+// The number of steps applied successfully is returned along with any error
+func (c Client) Apply(plan Plan) (int, error) {
+	if err := c.checkRoles("apply plan"); err != nil {
+		return 0, err
+	}
+	for i, step := range plan {
+		err := step.apply(c)
+		if err != nil {
+			return i, errors.Errorf("step %d of %d failed (%s) - %s", i+1, len(plan), step, err)
+		}
+	}
+	return len(plan), nil
+}
+
+// findSpecArray returns the live array matching a spec's deployment and name
+func findSpecArray(spec ArraySpec, deploymentList Deployments, arrayList ServerArrays) (ServerArray, error) {
+	var deploymentHref string
+	for _, deployment := range deploymentList {
+		if deployment.Name == spec.Deployment {
+			deploymentHref = deployment.Links.LinkValue("self")
+		}
+	}
+	if deploymentHref == "" {
+		return ServerArray{}, errors.Errorf("deployment %s not found", spec.Deployment)
+	}
+	for _, array := range arrayList {
+		if array.Name == spec.Name && array.Links.LinkValue("deployment") == deploymentHref {
+			return array, nil
+		}
+	}
+	return ServerArray{}, errors.Errorf("array %s not found in deployment %s", spec.Name, spec.Deployment)
+}
+
+// planArray returns the steps needed to bring a single array to its spec
+func (c Client) planArray(spec ArraySpec, array ServerArray) (Plan, error) {
+	var plan Plan
+	step := func(field, from, to string, apply func(c Client) error) {
+		plan = append(plan, PlanStep{Array: spec.Name, Deployment: spec.Deployment, Field: field, From: from, To: to, apply: apply})
+	}
+
+	if spec.Template != "" {
+		aid, _ := array.ArrayID()
+		current, err := c.ArrayTemplate(aid)
+		if err != nil {
+			return nil, err
+		}
+		if current.Name != spec.Template || current.Revision != *spec.TemplateRevision {
+			desired, err := c.ServerTemplateRevision(spec.Template, *spec.TemplateRevision)
+			if err != nil {
+				return nil, err
+			}
+			step("template", templateLabel(current), templateLabel(desired), func(c Client) error {
+				return c.UpdateArrayTemplate(array, desired)
+			})
+		}
+	}
+
+	if spec.MinCount != nil || spec.MaxCount != nil {
+		min, max, err := array.DefaultBounds()
+		if err != nil {
+			return nil, err
+		}
+		wantMin, wantMax := min, max
+		if spec.MinCount != nil {
+			wantMin = *spec.MinCount
+		}
+		if spec.MaxCount != nil {
+			wantMax = *spec.MaxCount
+		}
+		if wantMin > wantMax {
+			return nil, errors.Errorf("min count %d is greater than max count %d", wantMin, wantMax)
+		}
+		if wantMin != min || wantMax != max {
+			bounds := map[string]string{"min_count": strconv.Itoa(wantMin), "max_count": strconv.Itoa(wantMax)}
+			step("bounds", fmt.Sprintf("%d-%d", min, max), fmt.Sprintf("%d-%d", wantMin, wantMax), func(c Client) error {
+				return c.UpdateArray(array, map[string]interface{}{"elasticity_params": map[string]interface{}{"bounds": bounds}})
+			})
+		}
+	}
+
+	if len(spec.Schedule) > 0 {
+		from := scheduleLabel(array.ElasticityParams.ScheduleEntries)
+		to := scheduleLabel(spec.Schedule)
+		if from != to {
+			schedule := spec.Schedule
+			step("schedule", from, to, func(c Client) error {
+				return c.UpdateArray(array, map[string]interface{}{"elasticity_params": map[string]interface{}{"schedule": schedule}})
+			})
+		}
+	}
+
+	if len(spec.Inputs) > 0 {
+		inputList, err := c.ArrayInputs(array)
+		if err != nil {
+			return nil, err
+		}
+		current := map[string]string{}
+		for _, input := range inputList {
+			current[input.Name] = fmt.Sprintf("%s:%s", input.Kind, input.Value)
+		}
+		for _, name := range sortedKeys(spec.Inputs) {
+			value := spec.Inputs[name]
+			if current[name] == value {
+				continue
+			}
+			input := Input{Name: name, Value: value}
+			step("input "+name, current[name], value, func(c Client) error {
+				return c.ArrayInputUpdate(array, input)
+			})
+		}
+	}
+
+	if len(spec.Tags) > 0 {
+		current := map[string]string{}
+		for _, t := range array.ArrayTags {
+			current[t.Name] = t.Value
+		}
+		for _, name := range sortedKeys(spec.Tags) {
+			value := spec.Tags[name]
+			existing, ok := current[name]
+			if ok && existing == value {
+				continue
+			}
+			rawTag := fmt.Sprintf("ec2:%s=%s", name, value)
+			step("tag "+name, existing, value, func(c Client) error {
+				return c.AddTags([]string{array.id()}, []string{rawTag})
+			})
+		}
+	}
+	return plan, nil
+}
+
+// String returns a single line description of the step
+func (ps PlanStep) String() string {
+	from := ps.From
+	if from == "" {
+		from = "(unset)"
+	}
+	return fmt.Sprintf("%s/%s %s: %s -> %s", ps.Deployment, ps.Array, ps.Field, from, ps.To)
+}
+
+// String renders the plan as a readable diff grouped by array
+func (p Plan) String() string {
+	if len(p) == 0 {
+		return "No changes. Arrays match the desired state.\n"
+	}
+	var b strings.Builder
+	var lastArray string
+	for _, step := range p {
+		arrayKey := step.Deployment + "/" + step.Array
+		if arrayKey != lastArray {
+			fmt.Fprintf(&b, "~ array %s (deployment %s)\n", step.Array, step.Deployment)
+			lastArray = arrayKey
+		}
+		if step.From == "" {
+			fmt.Fprintf(&b, "    + %s: %s\n", step.Field, step.To)
+		} else {
+			fmt.Fprintf(&b, "    ~ %s: %s -> %s\n", step.Field, step.From, step.To)
+		}
+	}
+	fmt.Fprintf(&b, "Plan: %d change(s)\n", len(p))
+	return b.String()
+}
+
+// TableHeaders returns the headers for the plan
+func (p Plan) TableHeaders() []string {
+	return []string{"Deployment", "Array", "Field", "From", "To"}
+}
+
+// TableData returns the rows for the plan
+func (p Plan) TableData() [][]string {
+	var data [][]string
+	for _, step := range p {
+		data = append(data, []string{step.Deployment, step.Array, step.Field, step.From, step.To})
+	}
+	return data
+}
+
+// templateLabel formats a server template as name@revision
+func templateLabel(t ServerTemplate) string {
+	return fmt.Sprintf("%s@%d", t.Name, t.Revision)
+}
+
+// scheduleLabel formats a schedule so two schedules can be compared and shown in a plan
+func scheduleLabel(entries []ScheduleEntry) string {
+	var parts []string
+	for _, e := range entries {
+		parts = append(parts, fmt.Sprintf("%s %s %d-%d", e.Day, e.Time, e.MinCount, e.MaxCount))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sortedKeys returns the keys of a map in order so plans are stable between runs
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
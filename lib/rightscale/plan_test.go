@@ -0,0 +1,197 @@
+package rightscale
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestArraysFetchesEveryDeployment(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.serveDeployment("")
+
+	arrays, err := c.Arrays()
+	if err != nil {
+		t.Fatalf("Arrays() error = %s", err)
+	}
+	if len(arrays) != 1 || arrays[0].Name != "a1" {
+		t.Fatalf("Arrays() = %+v, want array a1", arrays)
+	}
+	if arrays[0].Href != "/api/server_arrays/10" {
+		t.Errorf("Href = %q, want /api/server_arrays/10", arrays[0].Href)
+	}
+}
+
+func TestArraysReturnsDeploymentErrors(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.on("GET", "/api/deployments", 200, "["+deploymentJSON+`,{"name":"d2","links":[`+
+		`{"rel":"self","href":"/api/deployments/2"},{"rel":"server_arrays","href":"/api/deployments/2/server_arrays"}]}]`)
+	api.on("GET", "/api/deployments/1/server_arrays?view=instance_detail", 200, "["+arrayJSON+"]")
+	api.on("GET", "/api/deployments/2/server_arrays?view=instance_detail", 500, "internal error")
+
+	arrays, err := c.Arrays()
+	if err == nil {
+		t.Fatalf("Arrays() = %d arrays, want an error", len(arrays))
+	}
+	if !strings.Contains(err.Error(), "/api/deployments/2/server_arrays") {
+		t.Errorf("error %q does not name the failed deployment", err)
+	}
+	if arrays != nil {
+		t.Errorf("Arrays() returned %d arrays along with the error", len(arrays))
+	}
+}
+
+func TestPlan(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.serveDeployment("")
+	api.on("GET", "/api/server_arrays/10?view=instance_detail", 200, arrayJSON)
+	api.on("GET", "/api/server_templates/5", 200, `{"name":"web","revision":3}`)
+	api.on("GET", "/api/server_templates?filter[]=name==web", 200, `[{"name":"web","revision":3},`+
+		`{"name":"web","revision":4,"links":[{"rel":"self","href":"/api/server_templates/6"}]}]`)
+
+	two, four := 2, 4
+	tests := []struct {
+		name string
+		spec ArraySpec
+		want []string
+		err  string
+	}{
+		{
+			name: "in sync",
+			spec: ArraySpec{Deployment: "d1", Name: "a1"},
+		},
+		{
+			name: "bounds and template",
+			spec: ArraySpec{Deployment: "d1", Name: "a1", MinCount: &two, Template: "web", TemplateRevision: &four},
+			want: []string{"d1/a1 template: web@3 -> web@4", "d1/a1 bounds: 1-3 -> 2-3"},
+		},
+		{
+			name: "missing array",
+			spec: ArraySpec{Deployment: "d1", Name: "a2"},
+			err:  "array a2 not found in deployment d1",
+		},
+		{
+			name: "missing deployment",
+			spec: ArraySpec{Deployment: "d2", Name: "a1"},
+			err:  "deployment d2 not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := c.Plan(DesiredState{Arrays: []ArraySpec{tt.spec}})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Plan() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Plan() error = %s", err)
+			}
+			var got []string
+			for _, step := range plan {
+				got = append(got, step.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Plan() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.serveDeployment("")
+	api.on("GET", "/api/server_arrays/10?view=instance_detail", 200, arrayJSON)
+	api.on("POST", "/api/tags/by_resource", 200, `[{"links":[{"rel":"resource","href":"/api/server_arrays/10"}],`+
+		`"tags":[{"name":"ec2:team=web"},{"name":"ec2:env=prod"}]}]`)
+	api.on("GET", "/api/server_templates/5", 200, `{"name":"web","revision":3}`)
+	api.on("GET", "/api/server_templates?filter[]=name==web", 200,
+		`[{"name":"web","revision":4,"links":[{"rel":"self","href":"/api/server_templates/6"}]}]`)
+	api.on("GET", "/api/clouds/1/instances/NEXT/inputs", 200, `[{"name":"PORT","value":"text:80"}]`)
+	api.on("PUT", "/api/clouds/1/instances/NEXT", 204, "")
+	api.on("PUT", "/api/server_arrays/10", 204, "")
+	api.on("PUT", "/api/clouds/1/instances/NEXT/inputs/multi_update", 204, "")
+	api.on("POST", "/api/tags/multi_add", 204, "")
+
+	two, four := 2, 4
+	state := DesiredState{Arrays: []ArraySpec{{
+		Deployment: "d1", Name: "a1", MinCount: &two, Template: "web", TemplateRevision: &four,
+		Inputs: map[string]string{"PORT": "text:8080"},
+		Tags:   map[string]string{"team": "db", "env": "prod"},
+	}}}
+	plan, err := c.Plan(state)
+	if err != nil {
+		t.Fatalf("Plan() error = %s", err)
+	}
+	n, err := c.Apply(plan)
+	if err != nil || n != 4 {
+		t.Fatalf("Apply() = %d, %v, want all 4 steps applied", n, err)
+	}
+
+	tests := []struct {
+		method, uri string
+		want        []string
+	}{
+		{method: "PUT", uri: "/api/clouds/1/instances/NEXT", want: []string{`{"instance":{"server_template_href":"/api/server_templates/6"}}`}},
+		{method: "PUT", uri: "/api/server_arrays/10", want: []string{`{"server_array":{"elasticity_params":{"bounds":{"max_count":"3","min_count":"2"}}}}`}},
+		{method: "PUT", uri: "/api/clouds/1/instances/NEXT/inputs/multi_update", want: []string{`{"inputs":{"PORT":"text:8080"}}`}},
+		//env is already prod so only team is tagged
+		{method: "POST", uri: "/api/tags/multi_add", want: []string{`{"resource_hrefs":["/api/server_arrays/10"],"tags":["ec2:team=db"]}`}},
+	}
+	for _, tt := range tests {
+		if got := api.bodies(tt.method, tt.uri); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s %s bodies = %q, want %q", tt.method, tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestApplyStopsAtFirstFailure(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.serveDeployment("")
+	api.on("POST", "/api/tags/by_resource", 200, "[]")
+	api.on("PUT", "/api/server_arrays/10", 500, `{"message":"boom"}`)
+	api.on("POST", "/api/tags/multi_add", 204, "")
+
+	two := 2
+	plan, err := c.Plan(DesiredState{Arrays: []ArraySpec{{Deployment: "d1", Name: "a1", MinCount: &two,
+		Tags: map[string]string{"team": "db"}}}})
+	if err != nil {
+		t.Fatalf("Plan() error = %s", err)
+	}
+	n, err := c.Apply(plan)
+	if n != 0 || err == nil || !strings.Contains(err.Error(), "step 1 of 2 failed") {
+		t.Fatalf("Apply() = %d, %v, want step 1 of 2 to fail", n, err)
+	}
+	if got := api.count("POST", "/api/tags/multi_add"); got != 0 {
+		t.Errorf("Apply() made %d tag calls after a failed step, want 0", got)
+	}
+}
+
+func TestParseDesiredState(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+		err    string
+	}{
+		{name: "yaml", format: "yaml", data: "arrays:\n- deployment: d1\n  name: a1\n  min_count: 2\n"},
+		{name: "json", format: "json", data: `{"arrays":[{"deployment":"d1","name":"a1"}]}`},
+		{name: "unknown field", format: "yml", data: "arrays:\n- deployment: d1\n  name: a1\n  size: 2\n", err: "could not parse"},
+		{name: "unknown json field", format: "json", data: `{"arrays":[{"deployment":"d1","name":"a1","size":2}]}`, err: "could not parse"},
+		{name: "missing name", format: "json", data: `{"arrays":[{"deployment":"d1"}]}`, err: "requires a deployment and a name"},
+		{name: "template without revision", format: "json", data: `{"arrays":[{"deployment":"d1","name":"a1","template":"web"}]}`,
+			err: "requires both template and template_revision"},
+		{name: "unsupported format", format: "toml", data: "", err: "unsupported desired state format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDesiredState([]byte(tt.data), tt.format)
+			if tt.err == "" && err != nil {
+				t.Fatalf("ParseDesiredState() error = %s", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("ParseDesiredState() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"net/url"
 	"os"
 	"sort"
 	"strconv"
@@ -37,12 +37,7 @@ type ServerArray struct {
 			ResizeDownBy   string `json:"resize_down_by"`
 			ResizeUpBy     string `json:"resize_up_by"`
 		} `json:"pacing"`
-		ScheduleEntries []struct {
-			Day      string `json:"day"`
-			MaxCount int    `json:"max_count"`
-			MinCount int    `json:"min_count"`
-			Time     string `json:"time"`
-		} `json:"schedule_entries"`
+		ScheduleEntries []ScheduleEntry `json:"schedule_entries"`
 	} `json:"elasticity_params"`
 	InstancesCount int            `json:"instances_count"`
 	Links          rsLinks        `json:"links"`
@@ -79,6 +74,7 @@ type ServerInstance struct {
 	ResourceUID         string   `json:"resource_uid"`
 	State               string   `json:"state"`
 	UpdatedAt           string   `json:"updated_at"`
+	InstanceTags        tags
 }
 
 // ServerInstances represents a collection of servicerInstance reosurces
@@ -133,13 +129,17 @@ type Input struct {
 type Inputs []Input
 
 type ServerTemplate struct {
-	Name     string `json:"name"`
-	Revision int `json:"revision"`
+	Name     string  `json:"name"`
+	Revision int     `json:"revision"`
+	Links    rsLinks `json:"links"`
 }
 
-func timeTrack(start time.Time, name string) {
+// ServerTemplates represents a collection of ServerTemplate resources
+type ServerTemplates []ServerTemplate
+
+func (c Client) timeTrack(start time.Time, name string) {
 	elapsed := time.Since(start)
-	log.Printf("%s took %s", name, elapsed)
+	c.log().Debug("rightscale timing", "name", name, "elapsed", elapsed)
 }
 
 // Arrays returns a list of arrays for a given Rightscale Account
@@ -148,6 +148,8 @@ func timeTrack(start time.Time, name string) {
 // set of arrays is a somewhat expensive operation
 // this function makes use of Goroutines and channels to speed up the retrieval of arrays,
 // this speedup is achieved by breaking the array request into multiple request group by rightscale deployment
+// If any deployment's arrays cannot be listed an error naming each failed deployment is returned and no arrays,
+// so callers never work from a partial list
 func (c Client) Arrays(withTags ...bool) (arrayList ServerArrays, e error) {
 	var wantTags bool
 	if len(withTags) > 0 && withTags[0] {
@@ -165,33 +167,44 @@ func (c Client) Arrays(withTags ...bool) (arrayList ServerArrays, e error) {
 		serverArrayHrefs = append(serverArrayHrefs, withDetail)
 	}
 	ch := make(chan ServerArray)
+	done := make(chan struct{})
 	var results ServerArrays
 	var loopGroup sync.WaitGroup
+	var mu sync.Mutex
+	var loopErrors []string
 	go func(arrays chan ServerArray) {
 		for a := range arrays {
 			results = append(results, a)
-
 		}
+		close(done)
 	}(ch)
 	for _, arrayHref := range serverArrayHrefs {
 		loopGroup.Add(1)
 		go func(href string, getTags bool, x *sync.WaitGroup, zzz chan ServerArray) {
+			defer x.Done()
 			sa, err := c.getArrays(href, getTags)
 			if err != nil {
-				log.Printf("Could not get arrays from %s - Error: %s", href, err)
+				mu.Lock()
+				loopErrors = append(loopErrors, fmt.Sprintf("%s: %s", href, err))
+				mu.Unlock()
+				return
 			}
 			//loop through arrays and push then into channel
 			for _, array := range sa {
 				//push here
 				zzz <- array
 			}
-
-			x.Done()
 		}(arrayHref, wantTags, &loopGroup, ch)
 
 	}
 	loopGroup.Wait()
 	close(ch)
+	<-done
+	if len(loopErrors) != 0 {
+		sort.Strings(loopErrors)
+		return nil, errors.Errorf("could not get arrays for %d of %d deployments - %s", len(loopErrors),
+			len(serverArrayHrefs), strings.Join(loopErrors, "; "))
+	}
 	return results, nil
 }
 
@@ -200,28 +213,22 @@ func (c Client) Arrays(withTags ...bool) (arrayList ServerArrays, e error) {
 // this url is used to pull the arrays. This function accepts an optional withTags
 // boolean parameter which indicates that it should pull in array meta data also
 func (c Client) getArrays(url string, withTags ...bool) (arrayList ServerArrays, e error) {
-	defer timeTrack(time.Now(), url)
-	// arrayListRequestParams := RequestParams{
-	// 	method: "GET",
-	// 	url:    url,
-	// }
-	//could not find symbol value for msg
-	var data []byte
-	var err error
-	// if mockRSCalls() {
-	// 	data = arrayListResponseMock
-	// } else {
-	// 	data, err = c.Request(arrayListRequestParams)
-	// 	if err != nil {
-	// 		return ServerArrays{}, errors.Errorf("encountered error requesting"+
-	// 			" server arrays to retrieve tags %s", err)
-	// 	}
-	// }
+	defer c.timeTrack(time.Now(), url)
+	arrayListRequestParams := RequestParams{
+		method: "GET",
+		url:    url,
+	}
+	data, err := c.Request(arrayListRequestParams)
+	if err != nil {
+		return ServerArrays{}, errors.Errorf("encountered error requesting server arrays %s", err)
+	}
 	err = json.Unmarshal(data, &arrayList)
 	if err != nil {
 		return nil, errors.Errorf("could not unmarshal json from get array api call %s", err)
 	}
-	//todo, ensure href being set like for single call
+	for i := range arrayList {
+		arrayList[i].Href = arrayList[i].id()
+	}
 	if len(withTags) > 0 && withTags[0] {
 		//If deployment contained no arrays we cannot further process things
 		if len(arrayList) == 0 {
@@ -296,6 +303,9 @@ func (c Client) Array(arrayID string, withTags ...bool) (array ServerArray, e er
 // Errors returned by this function will be from failed network calls to Rightscale or unexpected response status code
 // 200 and 201 are the only expected status codes for this call
 func (c Client) LaunchArrayInstances(array ServerArray, count int) error {
+	if err := c.checkRoles("launch array instances"); err != nil {
+		return err
+	}
 	path := fmt.Sprintf("%s/%s?count=%d&api_behavior=sync", array.Href, "launch", count)
 	arrayLaunchParams := RequestParams{"POST", path, nil}
 	resp, err := c.RequestDetailed(arrayLaunchParams)
@@ -316,85 +326,78 @@ func (c Client) LaunchArrayInstances(array ServerArray, count int) error {
 // DownscaleArrayInstances makes calls to Rightscale to terminate count number of instances in an Array
 // This function calls the TerminateInstance function to actually do the dirty work, this is just a wrapper
 // to control the amount and ensure the right set of instances are being killed.
-// This function factors instance age into the decision making process with a bias to killing older instances first
-// Errors returned by this function are the ones bubbled up from the TerminateInstances
-func (c Client) DownscaleArrayInstances(array ServerArray, count int) error {
-	var createdAtDateMap = make(map[int]ServerInstance) //this will stop working one day as unix timestamp will overflow 32 bits
-	var createdAtDateSlice []int
+// Instances are picked by the optional strategy, by default locked, non operational and do_not_terminate tagged
+// instances are skipped and the oldest of the rest are killed first. This default is stricter than it used to be,
+// when any instance which was not terminated could be picked, so a downscale now fails when too few instances are
+// operational and unprotected. Pass OldestFirst() as the strategy for the old behaviour.
+// Use DownscaleArrayInstancesDryRun to see which instances would be picked without terminating anything
+// Errors returned by this function are from selecting instances or the ones bubbled up from the TerminateInstances
+func (c Client) DownscaleArrayInstances(array ServerArray, count int, strategy ...VictimStrategy) error {
+	if err := c.checkRoles("downscale array instances"); err != nil {
+		return err
+	}
+	victims, err := c.DownscaleArrayInstancesDryRun(array, count, strategy...)
+	if err != nil {
+		return err
+	}
+	var terminatableHrefs []string
+	for _, victim := range victims {
+		c.log().Info("instance being submitted for termination", "instance", victim.Instance.Name, "reason", victim.Reason)
+		terminatableHrefs = append(terminatableHrefs, victim.Instance.Links.LinkValue("self"))
+	}
+	return c.TerminateInstances(terminatableHrefs)
+}
+
+// DownscaleArrayInstancesDryRun returns the instances DownscaleArrayInstances would terminate and why each was picked
+// Nothing is terminated by this function
+func (c Client) DownscaleArrayInstancesDryRun(array ServerArray, count int, strategy ...VictimStrategy) (Victims, error) {
+	var selector VictimStrategy = SkipProtected(OldestFirst(), DoNotTerminateTag)
+	if len(strategy) > 0 && strategy[0] != nil {
+		selector = strategy[0]
+	}
 	aid, _ := array.ArrayID()
 	instances, err := c.GetArrayInstances(aid)
-	if len(instances) < count {
-		return errors.Errorf("Count submitted for downscale %d higher than running count %d", count, len(instances))
-	}
 	if err != nil {
-		return errors.Errorf("Could not list array instances. Error %s", err)
+		return nil, errors.Errorf("Could not list array instances. Error %s", err)
 	}
-	//sort by created at time and select count oldest to send for termination
-	// date format 2012/12/24 13:27:58 +0000
+	var running ServerInstances
 	for _, i := range instances {
 		if i.State == "terminated" { //if an instance is in the terminated state then it should not be included
 			continue
 		}
-		t, err := time.Parse("2006/01/02 15:04:05 -0700", i.CreatedAt)
-		if err != nil {
-			log.Println("Could not parse time format for Instance", i.Name)
-		}
-		createdAtDateMap[int(t.Unix())] = i
-		createdAtDateSlice = append(createdAtDateSlice, int(t.Unix()))
+		running = append(running, i)
 	}
-	sort.Ints(createdAtDateSlice)
-	if count > len(createdAtDateSlice) {
-		return errors.Errorf("Count submitted for downscale %d higher"+
-			" than terminatable count in array %d", count, len(createdAtDateSlice))
+	if len(running) < count {
+		return nil, errors.Errorf("Count submitted for downscale %d higher than running count %d", count, len(running))
 	}
-	terminatable := createdAtDateSlice[0:count]
-	var terminatableHrefs []string
-	for _, ts := range terminatable {
-		instance, ok := createdAtDateMap[ts]
-		if ok {
-			log.Println("Instance being submitted for termination", instance.Name)
-			terminatableHrefs = append(terminatableHrefs, instance.Links.LinkValue("self"))
-		}
+	running, err = c.PopulateInstanceTags(running)
+	if err != nil {
+		return nil, err
 	}
-	return c.TerminateInstances(terminatableHrefs)
+	victims, err := selector.SelectVictims(running, count)
+	if err != nil {
+		return nil, errors.Errorf("Could not select instances for downscale of array %s - %s", array.Name, err)
+	}
+	if len(victims) < count {
+		return victims, errors.Errorf("Count submitted for downscale %d higher"+
+			" than terminatable count in array %d", count, len(victims))
+	}
+	return victims, nil
 }
 
 // TerminateInstances terminates instances contained within the instanceHref slice
-// Instances are terminated one by one in a loop and errors are all collected before returning
+// Instances are terminated in parallel and errors are all collected before returning, instances which
+// were already terminated are not treated as errors. Use TerminateInstancesDetailed for a per instance result
 // Errors returned by this function will be from network failures, unexpected response status codes,
 // or invalid IDs being passed in
 func (c Client) TerminateInstances(instanceHrefs []string) error {
-	var loopErrors []string
-	for _, href := range instanceHrefs {
-		log.Println("Terminating instance", href)
-		path := fmt.Sprintf("%s/%s", href, "terminate")
-		instanceTerminateParams := RequestParams{"POST", path, nil}
-		resp, err := c.RequestDetailed(instanceTerminateParams)
-		if err != nil {
-			loopErrors = append(loopErrors, fmt.Sprintf("Error calling terminate instance endpoint for %s - %s", href, err))
-		}
-		if resp.StatusCode != 204 {
-			responseBody, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				loopErrors = append(loopErrors, fmt.Sprintf("Error calling terminate instance endpoint for %s expected 204 got %d", href, resp.StatusCode))
-			} else {
-				loopErrors = append(loopErrors, fmt.Sprintf("Error calling terminate array endpoint for %s expected 204 got %d"+
-					" - %s", href, resp.StatusCode, string(responseBody)))
-			}
-		}
-
-	}
-	if len(loopErrors) != 0 {
-		return errors.Errorf("%s", strings.Join(loopErrors, " "))
-	}
-	return nil
+	return c.TerminateInstancesDetailed(instanceHrefs, 0).Err()
 }
 
 // ArrayInputs retrieves a list of Inputs from a given array for the "next instance"
 // This input set does not represent the inputs for currently running array instances
 // if inputs from currently running array instances are needed, use the InstanceInputs function
 func (c Client) ArrayInputs(array ServerArray) (inputList Inputs, e error) {
-	fmt.Println("ARRAY INPUTS FUNCTION")
 	nextInstance := array.Links.LinkValue("next_instance")
 	inputListRequestParams := RequestParams{
 		method: "GET",
@@ -421,6 +424,9 @@ func (c Client) ArrayInputs(array ServerArray) (inputList Inputs, e error) {
 // ArrayInputUpdate updates one input for the given array
 // Inputs are updated for the "next instance" of an array
 func (c Client) ArrayInputUpdate(array ServerArray, input Input) (e error) {
+	if err := c.checkRoles("update array inputs"); err != nil {
+		return err
+	}
 	newInput := map[string]string{}
 	newInput[input.Name] = input.Value
 	var body = map[string]map[string]string{}
@@ -519,6 +525,32 @@ func (c Client) PopulateArrayTags(arrayList ServerArrays) (ServerArrays, error)
 	return arrayList.associateArrayTags(arrayTags), nil
 }
 
+// PopulateInstanceTags take a list of Instances and supplements their data with their tag information
+// The return list represents the full list of instances passed in
+func (c Client) PopulateInstanceTags(instances ServerInstances) (ServerInstances, error) {
+	var refs []string
+	for _, instance := range instances {
+		if href := instance.id(); href != "" {
+			refs = append(refs, href)
+		}
+	}
+	//cannot further process
+	if len(refs) == 0 {
+		return instances, nil
+	}
+	tags, err := c.getTags(refs)
+	if err != nil {
+		return ServerInstances{}, errors.Errorf("encountered error requesting tags for server instances %s", err)
+	}
+	tagMap := tags.mapToArrayHREF()
+	var populated ServerInstances
+	for _, instance := range instances {
+		instance.InstanceTags = tagMap[instance.id()]
+		populated = append(populated, instance)
+	}
+	return populated, nil
+}
+
 // TagValue returns the tag value for a give tag name
 // this is a helper function to traversing the tag struct
 func (t tags) TagValue(name string) string {
@@ -540,11 +572,10 @@ func (c Client) getTags(refs []string) (rawTagListSlice, error) {
 		url:    "/api/tags/by_resource",
 		body:   body,
 	}
-	_ = tagRequestParams
-
-	var data []byte
-	var err error
-
+	data, err := c.Request(tagRequestParams)
+	if err != nil {
+		return rawTagListSlice{}, errors.Errorf("encountered error requesting tags %s", err)
+	}
 	err = json.Unmarshal(data, &tagList)
 	if err != nil {
 		return rawTagListSlice{}, errors.Errorf("encountered error attempting to unmarshal tag response %s", err)
@@ -552,6 +583,31 @@ func (c Client) getTags(refs []string) (rawTagListSlice, error) {
 	return tagList, nil
 }
 
+// AddTags adds tags to every resource in resourceHrefs
+// tag names are passed in their raw Rightscale form e.g. ec2:Name=value
+// 204 is the only expected status code for this call
+func (c Client) AddTags(resourceHrefs []string, tagNames []string) error {
+	if err := c.checkRoles("add tags"); err != nil {
+		return err
+	}
+	var body = make(map[string][]string)
+	body["resource_hrefs"] = resourceHrefs
+	body["tags"] = tagNames
+	tagAddParams := RequestParams{
+		method: "POST",
+		url:    "/api/tags/multi_add",
+		body:   body,
+	}
+	resp, err := c.RequestDetailed(tagAddParams)
+	if err != nil {
+		return errors.WithMessage(err, "Error calling add tags endpoint")
+	}
+	if resp.StatusCode == 204 {
+		return nil
+	}
+	return unexpectedStatus("add tags", 204, resp.StatusCode, resp.Body)
+}
+
 // mapToArrayHREF transforms Rightscale's obnoxious tag response to a toplevel object
 func (rawTagList rawTagListSlice) mapToArrayHREF() map[string]tags {
 	var tagMap = make(map[string]tags) //toplevel tag list to return
@@ -575,14 +631,19 @@ func (rawTagList rawTagListSlice) mapToArrayHREF() map[string]tags {
 }
 
 // extractRSEC2Tag identifies and returns ec2 tags from Rightscale resources
-// EC2 tags on Rightscale resources have the prefix ec2
+// EC2 tags on Rightscale resources have the prefix ec2, keys may contain ':' and values may contain ':' or '='
+// e.g. ec2:aws:autoscaling:groupName=web
 func extractRSEC2Tag(t string) (key, value string, e error) {
-	tagParts := strings.Split(t, ":")
-	if tagParts[0] != "ec2" {
+	tagParts := strings.SplitN(t, ":", 2)
+	if len(tagParts) < 2 || tagParts[0] != "ec2" {
 		e = errors.New("was not EC2 tag")
 		return
 	}
-	p2 := strings.Split(tagParts[1], "=")
+	p2 := strings.SplitN(tagParts[1], "=", 2)
+	if len(p2) < 2 {
+		e = errors.Errorf("EC2 tag %q has no value", t)
+		return
+	}
 	return p2[0], p2[1], nil
 }
 
@@ -621,7 +682,11 @@ func (c Client) ArrayTemplate(arrayID string) (template ServerTemplate, e error)
 	if err != nil {
 		return ServerTemplate{}, errors.Errorf("encountered error requesting server array %s", err)
 	}
-	templateHref := sa.NextInstance.Links.LinkValue("server_template")
+	return c.ServerTemplate(sa.NextInstance.Links.LinkValue("server_template"))
+}
+
+// ServerTemplate retrieves a single server template by its full href
+func (c Client) ServerTemplate(templateHref string) (template ServerTemplate, e error) {
 	templateRequestParams := RequestParams{
 		method: "GET",
 		url:    templateHref,
@@ -638,6 +703,81 @@ func (c Client) ArrayTemplate(arrayID string) (template ServerTemplate, e error)
 	return
 }
 
+// ServerTemplateRevision returns the server template with the given name and revision
+// Revision 0 is the HEAD revision of the template
+func (c Client) ServerTemplateRevision(name string, revision int) (ServerTemplate, error) {
+	templateListParams := RequestParams{
+		method: "GET",
+		url:    fmt.Sprintf("/api/server_templates?filter[]=name==%s", url.QueryEscape(name)),
+	}
+	data, err := c.Request(templateListParams)
+	if err != nil {
+		return ServerTemplate{}, errors.Errorf("encountered error requesting server templates %s", err)
+	}
+	var templates ServerTemplates
+	err = json.Unmarshal(data, &templates)
+	if err != nil {
+		return ServerTemplate{}, errors.WithMessage(err, "could not unmarshal Server Templates response")
+	}
+	for _, template := range templates {
+		//the name filter is a partial match so the name is checked again here
+		if template.Name == name && template.Revision == revision {
+			return template, nil
+		}
+	}
+	return ServerTemplate{}, errors.Errorf("could not find server template %s revision %d", name, revision)
+}
+
+// UpdateArray updates attributes of a server array
+// params are the server_array attributes to change e.g. {"elasticity_params": {"bounds": {"min_count": "2"}}}
+// 204 is the only expected status code for this call
+func (c Client) UpdateArray(array ServerArray, params map[string]interface{}) error {
+	if err := c.checkRoles("update array"); err != nil {
+		return err
+	}
+	defer c.invalidateArray(array)
+	arrayUpdateParams := RequestParams{
+		method: "PUT",
+		url:    array.id(),
+		body:   map[string]interface{}{"server_array": params},
+	}
+	resp, err := c.RequestDetailed(arrayUpdateParams)
+	if err != nil {
+		return errors.WithMessage(err, "Error calling update array endpoint")
+	}
+	if resp.StatusCode == 204 {
+		return nil
+	}
+	return unexpectedStatus("update array", 204, resp.StatusCode, resp.Body)
+}
+
+// UpdateArrayTemplate points the next instance of an array at a different server template revision
+// 204 is the only expected status code for this call
+func (c Client) UpdateArrayTemplate(array ServerArray, template ServerTemplate) error {
+	if err := c.checkRoles("update array template"); err != nil {
+		return err
+	}
+	//the template is read through the array, which the next instance update does not invalidate
+	defer c.invalidateArray(array)
+	templateHref := template.Links.LinkValue("self")
+	if templateHref == "" {
+		return errors.Errorf("server template %s revision %d has no href", template.Name, template.Revision)
+	}
+	instanceUpdateParams := RequestParams{
+		method: "PUT",
+		url:    array.Links.LinkValue("next_instance"),
+		body:   map[string]map[string]string{"instance": {"server_template_href": templateHref}},
+	}
+	resp, err := c.RequestDetailed(instanceUpdateParams)
+	if err != nil {
+		return errors.WithMessage(err, "Error calling update next instance endpoint")
+	}
+	if resp.StatusCode == 204 {
+		return nil
+	}
+	return unexpectedStatus("update next instance", 204, resp.StatusCode, resp.Body)
+}
+
 // LinkValues returns the value of a given link name,
 // no error is returned from this function. if the name is not found an empty string "" is returned
 func (links rsLinks) LinkValue(name string) string {
@@ -0,0 +1,277 @@
+package rightscale
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HealthGate decides whether a newly launched, operational instance is ready to take traffic
+// RollArray only counts an instance as a replacement once its health gate passes.
+// A nil HealthGate accepts every operational instance
+type HealthGate func(instance ServerInstance) (bool, error)
+
+// RollOptions tunes the behaviour of RollArray, the zero value is usable
+type RollOptions struct {
+	// PollInterval is how long to wait between checks of the array, defaults to 30 seconds
+	PollInterval time.Duration
+	// Timeout is how long the roll may go without progress before giving up, defaults to 30 minutes
+	Timeout time.Duration
+	// StateFile is where the roll records its progress, when it exists RollArray resumes from it
+	// and it is removed once the roll completes. Leave empty to keep state in memory only
+	StateFile string
+	// OnProgress is called whenever the roll launches, terminates or sees a new instance become healthy
+	OnProgress func(RollProgress)
+}
+
+// RollProgress describes the state of a roll at the time of a progress callback
+type RollProgress struct {
+	Phase      string
+	Old        int
+	Healthy    int
+	Pending    int
+	Target     int
+	Instances  []string
+	LastChange time.Time
+}
+
+// RollState is the persisted state of a roll, it lists the instances which were running when the roll
+// started. Every one of them is replaced, anything else in the array is considered a replacement.
+// Terminated lists the original instances the roll has already asked Rightscale to terminate
+type RollState struct {
+	Array      string    `json:"array"`
+	Started    time.Time `json:"started"`
+	Original   []string  `json:"original"`
+	Terminated []string  `json:"terminated,omitempty"`
+}
+
+// RollArray replaces every running instance in an array without dropping capacity
+// New instances are launched batchSize at a time, an old instance is only terminated once enough replacements
+// are operational and pass the healthGate to keep at least target-maxUnavailable instances serving, where target
+// is the number of instances running when the roll started. Progress is reported through opts.OnProgress and when
+// opts.StateFile is set the roll can be resumed after a crash by calling RollArray again with the same file.
+// Cancelling ctx stops the roll and any request in flight, a roll with a StateFile can be resumed later
+func (c Client) RollArray(ctx context.Context, array ServerArray, batchSize int, maxUnavailable int, healthGate HealthGate, opts ...RollOptions) error {
+	var o RollOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.PollInterval == 0 {
+		o.PollInterval = 30 * time.Second
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 30 * time.Minute
+	}
+	if batchSize < 1 {
+		return errors.Errorf("batch size must be at least 1, got %d", batchSize)
+	}
+	if maxUnavailable < 0 {
+		return errors.Errorf("max unavailable cannot be negative, got %d", maxUnavailable)
+	}
+	if err := c.checkRoles("roll array"); err != nil {
+		return err
+	}
+	//the roll polls the array's instances, so it must never be answered from the cache
+	c = c.WithContext(ctx).Uncached()
+	if array.Href == "" {
+		array.Href = array.id()
+	}
+	aid, _ := array.ArrayID()
+
+	state, err := c.loadRollState(array, aid, o.StateFile)
+	if err != nil {
+		return err
+	}
+	original := map[string]bool{}
+	for _, href := range state.Original {
+		original[href] = true
+	}
+	//terminate is asynchronous so terminated instances are still listed, often as operational, for a while
+	terminated := map[string]bool{}
+	for _, href := range state.Terminated {
+		terminated[href] = true
+	}
+	target := len(state.Original)
+	healthy := map[string]bool{}
+	lastChange := time.Now()
+	progress := func(phase string, hrefs []string, old, healthyCount, pending int) {
+		if o.OnProgress != nil {
+			o.OnProgress(RollProgress{Phase: phase, Old: old, Healthy: healthyCount, Pending: pending,
+				Target: target, Instances: hrefs, LastChange: lastChange})
+		}
+	}
+	wait := func() error {
+		timer := time.NewTimer(o.PollInterval)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return errors.Errorf("roll of array %s stopped - %s", array.Name, ctx.Err())
+		case <-timer.C:
+			return nil
+		}
+	}
+
+	for {
+		instances, err := c.GetArrayInstances(aid)
+		if err != nil {
+			return errors.Errorf("could not list instances while rolling array %s - %s", array.Name, err)
+		}
+		var old, replacements ServerInstances
+		var pending int
+		var newlyHealthy []string
+		for _, i := range instances {
+			switch {
+			case i.State == "terminated" || i.State == "decommissioning" || i.State == "terminating" || terminated[i.id()]:
+				continue
+			case original[i.id()]:
+				old = append(old, i)
+			case i.State == "stranded":
+				return errors.Errorf("replacement instance %s is stranded, stopping roll of array %s", i.Name, array.Name)
+			case i.State != "operational":
+				pending++
+			case healthy[i.id()]:
+				replacements = append(replacements, i)
+			default:
+				ok := true
+				if healthGate != nil {
+					ok, err = healthGate(i)
+					if err != nil {
+						return errors.Errorf("health gate failed for instance %s - %s", i.Name, err)
+					}
+				}
+				if !ok {
+					pending++
+					continue
+				}
+				healthy[i.id()] = true
+				replacements = append(replacements, i)
+				newlyHealthy = append(newlyHealthy, i.id())
+			}
+		}
+		if len(newlyHealthy) > 0 {
+			lastChange = time.Now()
+			progress("healthy", newlyHealthy, len(old), len(replacements), pending)
+		}
+		if len(old) == 0 {
+			progress("done", nil, 0, len(replacements), pending)
+			if o.StateFile != "" {
+				if err := os.Remove(o.StateFile); err != nil && !os.IsNotExist(err) {
+					return errors.Errorf("roll of array %s is done but its state file could not be removed - %s", array.Name, err)
+				}
+			}
+			return nil
+		}
+
+		//terminate as many old instances as capacity allows, oldest first
+		serving := len(old) + len(replacements)
+		canTerminate := serving - (target - maxUnavailable)
+		if canTerminate > batchSize {
+			canTerminate = batchSize
+		}
+		if canTerminate > len(old) {
+			canTerminate = len(old)
+		}
+		if canTerminate > 0 {
+			victims, err := OldestFirst().SelectVictims(old, canTerminate)
+			if err != nil {
+				return err
+			}
+			var hrefs []string
+			for _, v := range victims {
+				hrefs = append(hrefs, v.Instance.id())
+			}
+			results := c.TerminateInstancesDetailed(hrefs, 0)
+			var gone []string
+			for _, r := range results {
+				if r.Status != TerminateFailed {
+					terminated[r.Href] = true
+					state.Terminated = append(state.Terminated, r.Href)
+					gone = append(gone, r.Href)
+				}
+			}
+			if err := saveRollState(o.StateFile, state); err != nil {
+				return err
+			}
+			if err := results.Err(); err != nil {
+				return errors.Errorf("could not terminate old instances while rolling array %s - %s", array.Name, err)
+			}
+			lastChange = time.Now()
+			progress("terminate", gone, len(old)-len(gone), len(replacements), pending)
+		} else {
+			//launch replacements, keeping at most batchSize in flight
+			launch := batchSize - pending
+			if remaining := target - len(replacements) - pending; launch > remaining {
+				launch = remaining
+			}
+			if launch > 0 {
+				err = c.LaunchArrayInstances(array, launch)
+				if err != nil {
+					return errors.Errorf("could not launch replacement instances while rolling array %s - %s", array.Name, err)
+				}
+				lastChange = time.Now()
+				progress("launch", nil, len(old), len(replacements), pending+launch)
+			}
+		}
+
+		if time.Since(lastChange) > o.Timeout {
+			return errors.Errorf("roll of array %s made no progress in %s, %d old instances remain", array.Name, o.Timeout, len(old))
+		}
+		if err := wait(); err != nil {
+			return err
+		}
+	}
+}
+
+// loadRollState resumes a roll from stateFile when it exists, otherwise it records the array's running
+// instances as the set to replace and saves them to stateFile
+func (c Client) loadRollState(array ServerArray, arrayID string, stateFile string) (RollState, error) {
+	var state RollState
+	if stateFile != "" {
+		data, err := ioutil.ReadFile(stateFile)
+		if err == nil {
+			err = json.Unmarshal(data, &state)
+			if err != nil {
+				return RollState{}, errors.Errorf("could not parse roll state file %s - %s", stateFile, err)
+			}
+			if state.Array != array.id() {
+				return RollState{}, errors.Errorf("roll state file %s belongs to array %s not %s", stateFile, state.Array, array.id())
+			}
+			return state, nil
+		}
+		if !os.IsNotExist(err) {
+			return RollState{}, errors.Errorf("could not read roll state file %s - %s", stateFile, err)
+		}
+	}
+	instances, err := c.GetArrayInstances(arrayID)
+	if err != nil {
+		return RollState{}, errors.Errorf("could not list instances to start roll of array %s - %s", array.Name, err)
+	}
+	state = RollState{Array: array.id(), Started: time.Now()}
+	for _, i := range instances {
+		if i.State == "terminated" {
+			continue
+		}
+		state.Original = append(state.Original, i.id())
+	}
+	if err := saveRollState(stateFile, state); err != nil {
+		return RollState{}, err
+	}
+	return state, nil
+}
+
+// saveRollState writes state to stateFile, nothing is written when stateFile is empty
+func saveRollState(stateFile string, state RollState) error {
+	if stateFile == "" {
+		return nil
+	}
+	data, _ := json.MarshalIndent(state, "", "  ")
+	err := ioutil.WriteFile(stateFile, data, 0644)
+	if err != nil {
+		return errors.Errorf("could not write roll state file %s - %s", stateFile, err)
+	}
+	return nil
+}
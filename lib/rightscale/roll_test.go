@@ -0,0 +1,130 @@
+package rightscale
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeArray simulates an array whose launches take a poll to boot and whose terminations take two polls to show up
+type fakeArray struct {
+	mu         sync.Mutex
+	instances  []*fakeInstance
+	launched   int
+	terminates map[string]int
+	minServing int
+}
+
+type fakeInstance struct {
+	href      string
+	state     string
+	age       int
+	dyingIn   int
+	createdAt string
+}
+
+func (a *fakeArray) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/api/server_arrays/10/current_instances":
+		serving := 0
+		var list []map[string]interface{}
+		for _, i := range a.instances {
+			i.age++
+			switch {
+			case i.dyingIn > 0:
+				i.dyingIn--
+				if i.dyingIn == 0 {
+					i.state = "terminated"
+				}
+			case i.state == "pending" && i.age > 1:
+				i.state = "operational"
+			}
+			if i.state == "operational" && a.terminates[i.href] == 0 {
+				serving++
+			}
+			list = append(list, map[string]interface{}{"name": i.href, "state": i.state, "created_at": i.createdAt,
+				"links": []map[string]string{{"rel": "self", "href": i.href}}})
+		}
+		if serving < a.minServing {
+			a.minServing = serving
+		}
+		json.NewEncoder(w).Encode(list)
+	case r.Method == "POST" && r.URL.Path == "/api/server_arrays/10/launch":
+		count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+		for n := 0; n < count; n++ {
+			a.launched++
+			a.instances = append(a.instances, &fakeInstance{href: fmt.Sprintf("/api/clouds/1/instances/NEW%d", a.launched),
+				state: "pending", createdAt: fmt.Sprintf("2021/01/01 00:00:%02d +0000", a.launched)})
+		}
+		w.WriteHeader(201)
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/terminate"):
+		href := strings.TrimSuffix(r.URL.Path, "/terminate")
+		a.terminates[href]++
+		for _, i := range a.instances {
+			if i.href == href && i.dyingIn == 0 {
+				//the instance keeps showing as operational until it has been listed twice more
+				i.dyingIn = 3
+			}
+		}
+		w.WriteHeader(204)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestRollArray(t *testing.T) {
+	fake := &fakeArray{terminates: map[string]int{}, minServing: 100}
+	for n := 1; n <= 4; n++ {
+		fake.instances = append(fake.instances, &fakeInstance{href: fmt.Sprintf("/api/clouds/1/instances/OLD%d", n),
+			state: "operational", createdAt: fmt.Sprintf("2020/01/01 00:00:%02d +0000", n)})
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	c := Client{EndPoint: server.URL}
+	array := ServerArray{Name: "a1", Links: rsLinks{{Rel: "self", Href: "/api/server_arrays/10"}}}
+	stateFile := filepath.Join(t.TempDir(), "roll.json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := c.RollArray(ctx, array, 2, 1, nil, RollOptions{PollInterval: time.Millisecond, StateFile: stateFile})
+	if err != nil {
+		t.Fatalf("RollArray() error = %s", err)
+	}
+	for n := 1; n <= 4; n++ {
+		href := fmt.Sprintf("/api/clouds/1/instances/OLD%d", n)
+		if fake.terminates[href] != 1 {
+			t.Errorf("%s terminated %d times, want once", href, fake.terminates[href])
+		}
+	}
+	if fake.minServing < 3 {
+		t.Errorf("serving instances dropped to %d, want at least target 4 - max unavailable 1", fake.minServing)
+	}
+	if fake.launched != 4 {
+		t.Errorf("launched %d replacements, want 4", fake.launched)
+	}
+}
+
+func TestRollArrayStopsWhenCancelled(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.on("GET", "/api/server_arrays/10/current_instances", 200,
+		`[{"name":"i1","state":"operational","links":[{"rel":"self","href":"/api/clouds/1/instances/I1"}]}]`)
+	api.on("POST", "/api/server_arrays/10/launch?count=1&api_behavior=sync", 201, "")
+	array := ServerArray{Name: "a1", Links: rsLinks{{Rel: "self", Href: "/api/server_arrays/10"}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.RollArray(ctx, array, 1, 0, nil, RollOptions{PollInterval: time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Fatalf("RollArray() error = %v, want the roll to stop", err)
+	}
+}
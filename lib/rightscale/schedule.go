@@ -0,0 +1,206 @@
+package rightscale
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ScheduleEntry represents a single entry of an array's elasticity schedule
+// At the given day and time each week the array's bounds are changed to MinCount and MaxCount,
+// they stay in effect until the next entry in the schedule
+type ScheduleEntry struct {
+	Day      string `json:"day" yaml:"day"`
+	MaxCount int    `json:"max_count" yaml:"max_count"`
+	MinCount int    `json:"min_count" yaml:"min_count"`
+	Time     string `json:"time" yaml:"time"`
+}
+
+// EffectiveBounds represents the min and max count of an array at a point in time
+// Entry is nil when the array has no schedule and the bounds come straight from its elasticity params,
+// otherwise it is the schedule entry responsible for the bounds and Since is when that entry last took effect
+type EffectiveBounds struct {
+	MinCount int
+	MaxCount int
+	Entry    *ScheduleEntry
+	Since    time.Time
+}
+
+// BoundsChange represents a single point in a bounds timeline where the array's min/max counts change
+type BoundsChange struct {
+	At       time.Time
+	MinCount int
+	MaxCount int
+	Entry    ScheduleEntry
+}
+
+// BoundsTimeline is an ordered list of bounds changes, it implements the report.Table interface
+type BoundsTimeline []BoundsChange
+
+// TableHeaders returns the headers for the bounds timeline
+func (bt BoundsTimeline) TableHeaders() []string {
+	return []string{"At", "Day", "Time", "Min", "Max"}
+}
+
+// TableData returns the rows for the bounds timeline
+func (bt BoundsTimeline) TableData() [][]string {
+	var data [][]string
+	for _, change := range bt {
+		data = append(data, []string{
+			change.At.Format("Mon 2006/01/02 15:04 MST"),
+			change.Entry.Day,
+			change.Entry.Time,
+			strconv.Itoa(change.MinCount),
+			strconv.Itoa(change.MaxCount),
+		})
+	}
+	return data
+}
+
+// scheduleOccurrence is a schedule entry placed at a concrete time
+type scheduleOccurrence struct {
+	at    time.Time
+	index int
+}
+
+// weekdays maps the day names Rightscale accepts to a time.Weekday
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// clock returns the weekday, hour and minute a schedule entry fires at
+// Times are 24 hour clock in the form 15:04, seconds are accepted but ignored
+func (se ScheduleEntry) clock() (day time.Weekday, hour int, minute int, e error) {
+	day, ok := weekdays[strings.ToLower(strings.TrimSpace(se.Day))]
+	if !ok {
+		return 0, 0, 0, errors.Errorf("unrecognised schedule day %q", se.Day)
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		t, err := time.Parse(layout, strings.TrimSpace(se.Time))
+		if err == nil {
+			return day, t.Hour(), t.Minute(), nil
+		}
+	}
+	return 0, 0, 0, errors.Errorf("unrecognised schedule time %q for %s", se.Time, se.Day)
+}
+
+// DefaultBounds returns the array's min and max count from its elasticity params, ignoring the schedule
+func (sa ServerArray) DefaultBounds() (min int, max int, e error) {
+	bounds := sa.ElasticityParams.Bounds
+	min, err := strconv.Atoi(bounds.MinCount)
+	if err != nil {
+		return 0, 0, errors.Errorf("could not parse min count %q for array %s", bounds.MinCount, sa.Name)
+	}
+	max, err = strconv.Atoi(bounds.MaxCount)
+	if err != nil {
+		return 0, 0, errors.Errorf("could not parse max count %q for array %s", bounds.MaxCount, sa.Name)
+	}
+	return min, max, nil
+}
+
+// EffectiveBounds returns the array's min and max count at time t
+// loc is the time zone the schedule entries are written in, nil means UTC. The entry in effect is the last one
+// to fire at or before t, which may belong to the previous week when t is early in the week.
+// Arrays without a schedule return their default bounds
+func (sa ServerArray) EffectiveBounds(t time.Time, loc *time.Location) (EffectiveBounds, error) {
+	entries := sa.ElasticityParams.ScheduleEntries
+	if len(entries) == 0 {
+		min, max, err := sa.DefaultBounds()
+		if err != nil {
+			return EffectiveBounds{}, err
+		}
+		return EffectiveBounds{MinCount: min, MaxCount: max}, nil
+	}
+	occurrences, err := sa.scheduleOccurrences(t, loc, -1, 0)
+	if err != nil {
+		return EffectiveBounds{}, err
+	}
+	var current *scheduleOccurrence
+	for i := range occurrences {
+		if occurrences[i].at.After(t) {
+			break
+		}
+		current = &occurrences[i]
+	}
+	//a schedule with at least one entry always has an occurrence in the previous week
+	entry := entries[current.index]
+	return EffectiveBounds{
+		MinCount: entry.MinCount,
+		MaxCount: entry.MaxCount,
+		Entry:    &entry,
+		Since:    current.at,
+	}, nil
+}
+
+// BoundsTimeline returns every bounds change in the week starting at start
+// The first item in the timeline is the bounds in effect at start, so the result always describes the full week.
+// loc is the time zone the schedule entries are written in, nil means UTC
+func (sa ServerArray) BoundsTimeline(start time.Time, loc *time.Location) (BoundsTimeline, error) {
+	var timeline BoundsTimeline
+	current, err := sa.EffectiveBounds(start, loc)
+	if err != nil {
+		return nil, err
+	}
+	first := BoundsChange{At: start, MinCount: current.MinCount, MaxCount: current.MaxCount}
+	if current.Entry != nil {
+		first.Entry = *current.Entry
+	}
+	timeline = append(timeline, first)
+	if len(sa.ElasticityParams.ScheduleEntries) == 0 {
+		return timeline, nil
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	//a week is 7 calendar days in the schedule's zone, which is an hour more or less when it crosses a DST change
+	end := start.In(loc).AddDate(0, 0, 7)
+	occurrences, err := sa.scheduleOccurrences(start, loc, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range occurrences {
+		if !o.at.After(start) || !o.at.Before(end) {
+			continue
+		}
+		entry := sa.ElasticityParams.ScheduleEntries[o.index]
+		timeline = append(timeline, BoundsChange{At: o.at, MinCount: entry.MinCount, MaxCount: entry.MaxCount, Entry: entry})
+	}
+	return timeline, nil
+}
+
+// scheduleOccurrences places every schedule entry at a concrete time for each week from fromWeek to toWeek
+// relative to the week containing t. Weeks start on Sunday at midnight in loc. The result is sorted by time,
+// entries firing at the same instant keep their schedule order so the later one wins
+func (sa ServerArray) scheduleOccurrences(t time.Time, loc *time.Location, fromWeek int, toWeek int) ([]scheduleOccurrence, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	year, month, day := local.Date()
+	weekStart := day - int(local.Weekday())
+	var occurrences []scheduleOccurrence
+	for i, entry := range sa.ElasticityParams.ScheduleEntries {
+		weekday, hour, minute, err := entry.clock()
+		if err != nil {
+			return nil, errors.Errorf("invalid schedule for array %s - %s", sa.Name, err)
+		}
+		for week := fromWeek; week <= toWeek; week++ {
+			//time.Date normalises out of range days and handles DST transitions in loc
+			at := time.Date(year, month, weekStart+week*7+int(weekday), hour, minute, 0, 0, loc)
+			occurrences = append(occurrences, scheduleOccurrence{at: at, index: i})
+		}
+	}
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].at.Before(occurrences[j].at)
+	})
+	return occurrences, nil
+}
@@ -0,0 +1,139 @@
+package rightscale
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// scheduledArray builds an array with default bounds 1-5 and the given schedule
+func scheduledArray(entries ...ScheduleEntry) ServerArray {
+	var sa ServerArray
+	sa.Name = "a1"
+	sa.ElasticityParams.Bounds.MinCount = "1"
+	sa.ElasticityParams.Bounds.MaxCount = "5"
+	sa.ElasticityParams.ScheduleEntries = entries
+	return sa
+}
+
+func TestEffectiveBounds(t *testing.T) {
+	weekly := scheduledArray(
+		ScheduleEntry{Day: "Monday", Time: "08:00", MinCount: 4, MaxCount: 10},
+		ScheduleEntry{Day: "Friday", Time: "18:00", MinCount: 2, MaxCount: 6},
+	)
+	tests := []struct {
+		name  string
+		array ServerArray
+		at    string
+		min   int
+		max   int
+		since string
+	}{
+		{name: "no schedule", array: scheduledArray(), at: "2021-03-03T12:00:00Z", min: 1, max: 5},
+		{name: "mid week", array: weekly, at: "2021-03-03T12:00:00Z", min: 4, max: 10, since: "2021-03-01T08:00:00Z"},
+		{name: "at an entry", array: weekly, at: "2021-03-05T18:00:00Z", min: 2, max: 6, since: "2021-03-05T18:00:00Z"},
+		{name: "just before an entry", array: weekly, at: "2021-03-05T17:59:00Z", min: 4, max: 10, since: "2021-03-01T08:00:00Z"},
+		{name: "sunday wraps to last week", array: weekly, at: "2021-03-07T00:00:00Z", min: 2, max: 6, since: "2021-03-05T18:00:00Z"},
+		{name: "monday before first entry", array: weekly, at: "2021-03-08T07:00:00Z", min: 2, max: 6, since: "2021-03-05T18:00:00Z"},
+		{
+			name: "same instant keeps the later entry",
+			array: scheduledArray(
+				ScheduleEntry{Day: "wed", Time: "09:00", MinCount: 3, MaxCount: 3},
+				ScheduleEntry{Day: "Wednesday", Time: "09:00:00", MinCount: 7, MaxCount: 7},
+			),
+			at: "2021-03-03T10:00:00Z", min: 7, max: 7, since: "2021-03-03T09:00:00Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, _ := time.Parse(time.RFC3339, tt.at)
+			bounds, err := tt.array.EffectiveBounds(at, nil)
+			if err != nil {
+				t.Fatalf("EffectiveBounds() error = %s", err)
+			}
+			if bounds.MinCount != tt.min || bounds.MaxCount != tt.max {
+				t.Errorf("EffectiveBounds() = %d-%d, want %d-%d", bounds.MinCount, bounds.MaxCount, tt.min, tt.max)
+			}
+			since := ""
+			if !bounds.Since.IsZero() {
+				since = bounds.Since.Format(time.RFC3339)
+			}
+			if since != tt.since {
+				t.Errorf("Since = %q, want %q", since, tt.since)
+			}
+		})
+	}
+}
+
+func TestEffectiveBoundsInvalidSchedule(t *testing.T) {
+	for _, entry := range []ScheduleEntry{{Day: "Someday", Time: "08:00"}, {Day: "Monday", Time: "8am"}} {
+		if _, err := scheduledArray(entry).EffectiveBounds(time.Now(), nil); err == nil {
+			t.Errorf("EffectiveBounds() with %+v error = nil, want the schedule rejected", entry)
+		}
+	}
+}
+
+func TestBoundsTimeline(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		array   ServerArray
+		start   time.Time
+		loc     *time.Location
+		changes []string
+	}{
+		{
+			name: "utc week",
+			array: scheduledArray(ScheduleEntry{Day: "Monday", Time: "00:00", MinCount: 4, MaxCount: 10},
+				ScheduleEntry{Day: "Friday", Time: "18:00", MinCount: 2, MaxCount: 6}),
+			start:   time.Date(2021, 3, 3, 12, 0, 0, 0, time.UTC),
+			changes: []string{"Wed 12:00 4-10", "Fri 18:00 2-6", "Mon 00:00 4-10"},
+		},
+		{
+			name: "week gaining DST ends on monday midnight",
+			array: scheduledArray(ScheduleEntry{Day: "Monday", Time: "00:00", MinCount: 4, MaxCount: 10},
+				ScheduleEntry{Day: "Friday", Time: "18:00", MinCount: 2, MaxCount: 6}),
+			start:   time.Date(2021, 3, 8, 0, 0, 0, 0, newYork),
+			loc:     newYork,
+			changes: []string{"Mon 00:00 4-10", "Fri 18:00 2-6"},
+		},
+		{
+			name: "week losing DST keeps its last hour",
+			array: scheduledArray(ScheduleEntry{Day: "Monday", Time: "00:00", MinCount: 4, MaxCount: 10},
+				ScheduleEntry{Day: "Sunday", Time: "23:30", MinCount: 2, MaxCount: 6}),
+			start:   time.Date(2021, 11, 1, 0, 0, 0, 0, newYork),
+			loc:     newYork,
+			changes: []string{"Mon 00:00 4-10", "Sun 23:30 2-6"},
+		},
+		{
+			name:    "no schedule",
+			array:   scheduledArray(),
+			start:   time.Date(2021, 3, 3, 12, 0, 0, 0, time.UTC),
+			changes: []string{"Wed 12:00 1-5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeline, err := tt.array.BoundsTimeline(tt.start, tt.loc)
+			if err != nil {
+				t.Fatalf("BoundsTimeline() error = %s", err)
+			}
+			var got []string
+			for _, change := range timeline {
+				at := change.At
+				if tt.loc != nil {
+					at = at.In(tt.loc)
+				}
+				got = append(got, fmt.Sprintf("%s %d-%d", at.Format("Mon 15:04"), change.MinCount, change.MaxCount))
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.changes, ", ") {
+				t.Errorf("BoundsTimeline() = %q, want %q", got, tt.changes)
+			}
+		})
+	}
+}
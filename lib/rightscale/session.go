@@ -0,0 +1,147 @@
+package rightscale
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInsufficientRole is returned by mutating calls on a preflighted client when the token lacks a required role
+var ErrInsufficientRole = errors.New("insufficient role")
+
+// DefaultRequiredRoles are the roles WithPreflight requires when none are given, actor to change arrays and
+// server_login as the library's array operations are used to manage running servers
+var DefaultRequiredRoles = []string{"actor", "server_login"}
+
+// Identity describes who a client's token belongs to and what it may do
+type Identity struct {
+	UserHref    string
+	AccountHref string
+	Email       string
+	FirstName   string
+	LastName    string
+	Roles       []string
+}
+
+// preflight holds the roles looked up by WithPreflight and the roles mutating calls require
+type preflight struct {
+	held     []string
+	required []string
+}
+
+// rawSession is the whoami view of the sessions resource
+type rawSession struct {
+	Links rsLinks `json:"links"`
+}
+
+// rawUser is the part of the users resource WhoAmI needs
+type rawUser struct {
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// rawPermission is a single role granted to a user in an account
+type rawPermission struct {
+	RoleTitle string  `json:"role_title"`
+	Links     rsLinks `json:"links"`
+}
+
+// HasRole reports whether the identity holds the given role
+func (i Identity) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// WhoAmI returns the user and account the client's token belongs to along with the user's roles in that account
+func (c Client) WhoAmI() (Identity, error) {
+	sessionParams := RequestParams{
+		method: "GET",
+		url:    "/api/sessions?view=whoami",
+	}
+	data, err := c.Request(sessionParams)
+	if err != nil {
+		return Identity{}, errors.Errorf("encountered error requesting session %s", err)
+	}
+	var session rawSession
+	err = json.Unmarshal(data, &session)
+	if err != nil {
+		return Identity{}, errors.WithMessage(err, "could not unmarshal Session response")
+	}
+	id := Identity{
+		UserHref:    session.Links.LinkValue("user"),
+		AccountHref: session.Links.LinkValue("account"),
+	}
+	if id.UserHref == "" {
+		return Identity{}, errors.New("session response did not include a user")
+	}
+
+	data, err = c.Request(RequestParams{method: "GET", url: id.UserHref})
+	if err != nil {
+		return Identity{}, errors.Errorf("encountered error requesting user %s", err)
+	}
+	var user rawUser
+	err = json.Unmarshal(data, &user)
+	if err != nil {
+		return Identity{}, errors.WithMessage(err, "could not unmarshal User response")
+	}
+	id.Email, id.FirstName, id.LastName = user.Email, user.FirstName, user.LastName
+
+	data, err = c.Request(RequestParams{method: "GET", url: fmt.Sprintf("/api/permissions?filter[]=user_href==%s", id.UserHref)})
+	if err != nil {
+		return Identity{}, errors.Errorf("encountered error requesting permissions %s", err)
+	}
+	var permissions []rawPermission
+	err = json.Unmarshal(data, &permissions)
+	if err != nil {
+		return Identity{}, errors.WithMessage(err, "could not unmarshal Permissions response")
+	}
+	for _, p := range permissions {
+		if id.AccountHref != "" && p.Links.LinkValue("account") != "" && p.Links.LinkValue("account") != id.AccountHref {
+			continue
+		}
+		id.Roles = append(id.Roles, p.RoleTitle)
+	}
+	return id, nil
+}
+
+// WithPreflight returns a copy of the client which checks its roles before every mutating call
+// The roles are looked up once here. required lists the roles mutating calls need and defaults to DefaultRequiredRoles.
+// Calls made without a required role return ErrInsufficientRole before anything is sent to Rightscale
+func (c Client) WithPreflight(required ...string) (Client, error) {
+	if len(required) == 0 {
+		required = DefaultRequiredRoles
+	}
+	id, err := c.WhoAmI()
+	if err != nil {
+		return c, errors.Errorf("could not preflight client %s", err)
+	}
+	c.preflight = &preflight{held: id.Roles, required: required}
+	return c, nil
+}
+
+// checkRoles returns ErrInsufficientRole when the client was preflighted and lacks a required role
+// operation names the call being checked for the error message
+func (c Client) checkRoles(operation string) error {
+	if c.preflight == nil {
+		return nil
+	}
+	held := Identity{Roles: c.preflight.held}
+	var missing []string
+	for _, role := range c.preflight.required {
+		if !held.HasRole(role) {
+			missing = append(missing, role)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return errors.Wrapf(ErrInsufficientRole, "%s requires role(s) %s, token has %s",
+		operation, strings.Join(missing, ", "), strings.Join(c.preflight.held, ", "))
+}
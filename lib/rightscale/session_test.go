@@ -0,0 +1,41 @@
+package rightscale
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestWithPreflight(t *testing.T) {
+	tests := []struct {
+		name     string
+		roles    string
+		required []string
+		allowed  bool
+	}{
+		{name: "observer", roles: `[{"role_title":"observer"}]`},
+		{name: "actor only", roles: `[{"role_title":"observer"},{"role_title":"actor"}]`},
+		{name: "actor and server_login", roles: `[{"role_title":"actor"},{"role_title":"server_login"}]`, allowed: true},
+		{name: "custom requirement", roles: `[{"role_title":"actor"}]`, required: []string{"actor"}, allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, c := newFakeAPI(t)
+			api.on("GET", "/api/sessions?view=whoami", 200, `{"links":[{"rel":"user","href":"/api/users/1"}]}`)
+			api.on("GET", "/api/users/1", 200, `{"email":"ops@example.com"}`)
+			api.on("GET", "/api/permissions?filter[]=user_href==/api/users/1", 200, tt.roles)
+
+			c, err := c.WithPreflight(tt.required...)
+			if err != nil {
+				t.Fatalf("WithPreflight() error = %s", err)
+			}
+			err = c.checkRoles("launch array instances")
+			if tt.allowed && err != nil {
+				t.Errorf("checkRoles() error = %s, want allowed", err)
+			}
+			if !tt.allowed && errors.Cause(err) != ErrInsufficientRole {
+				t.Errorf("checkRoles() error = %v, want ErrInsufficientRole", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package rightscale
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultSnapshotParallelism is the number of arrays TakeSnapshot captures at once
+const DefaultSnapshotParallelism = 5
+
+// SnapshotVersion is the version of the snapshot file format written by SaveSnapshot
+const SnapshotVersion = 1
+
+// Snapshot is the full inventory of an account at a point in time
+type Snapshot struct {
+	Version     int             `json:"version"`
+	TakenAt     time.Time       `json:"taken_at"`
+	Account     string          `json:"account"`
+	EndPoint    string          `json:"endpoint"`
+	Deployments Deployments     `json:"deployments"`
+	Arrays      []ArraySnapshot `json:"arrays"`
+}
+
+// ArraySnapshot is a server array along with its running instances, next instance inputs and server template
+type ArraySnapshot struct {
+	Deployment string          `json:"deployment"`
+	Array      ServerArray     `json:"array"`
+	Instances  ServerInstances `json:"instances"`
+	Inputs     Inputs          `json:"inputs"`
+	Template   ServerTemplate  `json:"template"`
+}
+
+// SnapshotChange is a single difference between two snapshots
+type SnapshotChange struct {
+	Deployment string
+	Array      string
+	Change     string
+	From       string
+	To         string
+}
+
+// SnapshotDiff is the list of differences between two snapshots, it implements the report.Table interface
+type SnapshotDiff []SnapshotChange
+
+// TakeSnapshot captures the account's deployments and arrays, with each array's tags, instances, inputs and template
+// At most DefaultSnapshotParallelism arrays are captured at once, any failure fails the whole snapshot so it is never
+// silently partial. Arrays are sorted by deployment and array name
+func (c Client) TakeSnapshot() (Snapshot, error) {
+	snapshot := Snapshot{Version: SnapshotVersion, TakenAt: time.Now().UTC(), Account: c.Account, EndPoint: c.EndPoint}
+	deploymentList, err := c.GetDeployments()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.Deployments = deploymentList
+	deploymentNames := map[string]string{}
+	for _, d := range deploymentList {
+		deploymentNames[d.Links.LinkValue("self")] = d.Name
+	}
+	arrayList, err := c.Arrays(true)
+	if err != nil {
+		return Snapshot{}, errors.Errorf("could not snapshot arrays %s", err)
+	}
+	snapshot.Arrays = make([]ArraySnapshot, len(arrayList))
+	var mu sync.Mutex
+	var loopErrors []error
+	slots := make(chan struct{}, DefaultSnapshotParallelism)
+	var loopGroup sync.WaitGroup
+	for n, array := range arrayList {
+		loopGroup.Add(1)
+		slots <- struct{}{}
+		go func(n int, array ServerArray) {
+			defer loopGroup.Done()
+			as, err := c.snapshotArray(array)
+			<-slots
+			if err != nil {
+				mu.Lock()
+				loopErrors = append(loopErrors, errors.Errorf("array %s - %s", array.Name, err))
+				mu.Unlock()
+				return
+			}
+			as.Deployment = deploymentNames[array.Links.LinkValue("deployment")]
+			snapshot.Arrays[n] = as
+		}(n, array)
+	}
+	loopGroup.Wait()
+	if len(loopErrors) != 0 {
+		return Snapshot{}, errors.Errorf("could not snapshot %d arrays, first error %s", len(loopErrors), loopErrors[0])
+	}
+	sort.SliceStable(snapshot.Arrays, func(i, j int) bool {
+		a, b := snapshot.Arrays[i], snapshot.Arrays[j]
+		if a.Deployment != b.Deployment {
+			return a.Deployment < b.Deployment
+		}
+		if a.Array.Name != b.Array.Name {
+			return a.Array.Name < b.Array.Name
+		}
+		//ids are numeric so a shorter href is the lower id, e.g. /api/server_arrays/9 before /api/server_arrays/10
+		if len(a.Array.id()) != len(b.Array.id()) {
+			return len(a.Array.id()) < len(b.Array.id())
+		}
+		return a.Array.id() < b.Array.id()
+	})
+	return snapshot, nil
+}
+
+// snapshotArray gathers the instances, inputs and template of a single array
+func (c Client) snapshotArray(array ServerArray) (ArraySnapshot, error) {
+	as := ArraySnapshot{Array: array}
+	aid, _ := array.ArrayID()
+	instances, err := c.GetArrayInstances(aid)
+	if err != nil {
+		return as, err
+	}
+	as.Instances = instances
+	inputList, err := c.ArrayInputs(array)
+	if err != nil {
+		return as, err
+	}
+	as.Inputs = inputList
+	if templateHref := array.NextInstance.Links.LinkValue("server_template"); templateHref != "" {
+		as.Template, err = c.ServerTemplate(templateHref)
+		if err != nil {
+			return as, err
+		}
+	}
+	return as, nil
+}
+
+// SaveSnapshot writes a snapshot to path as indented JSON
+func SaveSnapshot(snapshot Snapshot, path string) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return errors.Errorf("could not marshal snapshot %s", err)
+	}
+	err = ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		return errors.Errorf("could not write snapshot file %s - %s", path, err)
+	}
+	return nil
+}
+
+// LoadSnapshot reads a snapshot written by SaveSnapshot
+// An error is returned for snapshots written by a newer version of the library
+func LoadSnapshot(path string) (Snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Snapshot{}, errors.Errorf("could not read snapshot file %s - %s", path, err)
+	}
+	var snapshot Snapshot
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return Snapshot{}, errors.Errorf("could not parse snapshot file %s - %s", path, err)
+	}
+	if snapshot.Version < 1 || snapshot.Version > SnapshotVersion {
+		return Snapshot{}, errors.Errorf("snapshot file %s has unsupported version %d, expected at most %d", path, snapshot.Version, SnapshotVersion)
+	}
+	return snapshot, nil
+}
+
+// DiffSnapshots returns what changed between an older and a newer snapshot
+// Arrays are matched by href. Added and removed arrays are reported along with changes to state, bounds,
+// schedule, template revision, inputs and tags of arrays present in both
+func DiffSnapshots(older Snapshot, newer Snapshot) SnapshotDiff {
+	var diff SnapshotDiff
+	before := map[string]ArraySnapshot{}
+	for _, as := range older.Arrays {
+		before[as.Array.id()] = as
+	}
+	after := map[string]ArraySnapshot{}
+	for _, as := range newer.Arrays {
+		after[as.Array.id()] = as
+	}
+	for _, as := range newer.Arrays {
+		old, ok := before[as.Array.id()]
+		if !ok {
+			diff = append(diff, SnapshotChange{Deployment: as.Deployment, Array: as.Array.Name, Change: "array added", To: as.Array.id()})
+			continue
+		}
+		diff = append(diff, diffArraySnapshots(old, as)...)
+	}
+	for _, as := range older.Arrays {
+		if _, ok := after[as.Array.id()]; !ok {
+			diff = append(diff, SnapshotChange{Deployment: as.Deployment, Array: as.Array.Name, Change: "array removed", From: as.Array.id()})
+		}
+	}
+	return diff
+}
+
+// diffArraySnapshots compares two snapshots of the same array
+func diffArraySnapshots(old ArraySnapshot, current ArraySnapshot) SnapshotDiff {
+	var diff SnapshotDiff
+	change := func(what, from, to string) {
+		if from != to {
+			diff = append(diff, SnapshotChange{Deployment: current.Deployment, Array: current.Array.Name, Change: what, From: from, To: to})
+		}
+	}
+	change("name", old.Array.Name, current.Array.Name)
+	change("state", old.Array.State, current.Array.State)
+	oldBounds := old.Array.ElasticityParams.Bounds
+	currentBounds := current.Array.ElasticityParams.Bounds
+	change("bounds", fmt.Sprintf("%s-%s", oldBounds.MinCount, oldBounds.MaxCount), fmt.Sprintf("%s-%s", currentBounds.MinCount, currentBounds.MaxCount))
+	change("schedule", scheduleLabel(old.Array.ElasticityParams.ScheduleEntries), scheduleLabel(current.Array.ElasticityParams.ScheduleEntries))
+	change("template", templateLabel(old.Template), templateLabel(current.Template))
+	change("instances", fmt.Sprint(len(old.Instances)), fmt.Sprint(len(current.Instances)))
+
+	oldInputs, currentInputs := map[string]string{}, map[string]string{}
+	for _, i := range old.Inputs {
+		oldInputs[i.Name] = inputLabel(i)
+	}
+	for _, i := range current.Inputs {
+		currentInputs[i.Name] = inputLabel(i)
+	}
+	for _, name := range unionKeys(oldInputs, currentInputs) {
+		change("input "+name, oldInputs[name], currentInputs[name])
+	}
+
+	oldTags, currentTags := map[string]string{}, map[string]string{}
+	for _, t := range old.Array.ArrayTags {
+		oldTags[t.Name] = t.Value
+	}
+	for _, t := range current.Array.ArrayTags {
+		currentTags[t.Name] = t.Value
+	}
+	for _, name := range unionKeys(oldTags, currentTags) {
+		change("tag "+name, oldTags[name], currentTags[name])
+	}
+	return diff
+}
+
+// inputLabel describes an input's value, Rightscale usually puts the kind in the value itself e.g. text:80
+func inputLabel(i Input) string {
+	if i.Kind == "" {
+		return i.Value
+	}
+	return fmt.Sprintf("%s:%s", i.Kind, i.Value)
+}
+
+// unionKeys returns the keys present in either map in order
+func unionKeys(a map[string]string, b map[string]string) []string {
+	merged := map[string]string{}
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return sortedKeys(merged)
+}
+
+// TableHeaders returns the headers for a snapshot diff
+func (sd SnapshotDiff) TableHeaders() []string {
+	return []string{"Deployment", "Array", "Change", "From", "To"}
+}
+
+// TableData returns the rows for a snapshot diff
+func (sd SnapshotDiff) TableData() [][]string {
+	var data [][]string
+	for _, c := range sd {
+		data = append(data, []string{c.Deployment, c.Array, c.Change, c.From, c.To})
+	}
+	return data
+}
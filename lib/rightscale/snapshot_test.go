@@ -0,0 +1,191 @@
+package rightscale
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTakeSnapshot(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.serveDeployment("")
+	api.on("POST", "/api/tags/by_resource", 200, `[{"links":[{"rel":"resource","href":"/api/server_arrays/10"}],`+
+		`"tags":[{"name":"ec2:team=web"}]}]`)
+	api.on("GET", "/api/server_arrays/10/current_instances", 200, `[{"name":"i1","state":"operational"}]`)
+	api.on("GET", "/api/clouds/1/instances/NEXT/inputs", 200, `[{"name":"PORT","value":"text:80"}]`)
+	api.on("GET", "/api/server_templates/5", 200, `{"name":"web","revision":3}`)
+
+	snapshot, err := c.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("TakeSnapshot() error = %s", err)
+	}
+	if len(snapshot.Arrays) != 1 {
+		t.Fatalf("TakeSnapshot() captured %d arrays, want 1", len(snapshot.Arrays))
+	}
+	as := snapshot.Arrays[0]
+	if as.Deployment != "d1" || as.Array.Name != "a1" {
+		t.Errorf("array = %s/%s, want d1/a1", as.Deployment, as.Array.Name)
+	}
+	if as.Array.ArrayTags.TagValue("team") != "web" {
+		t.Errorf("team tag = %q, want web", as.Array.ArrayTags.TagValue("team"))
+	}
+	if len(as.Instances) != 1 || len(as.Inputs) != 1 || as.Template.Revision != 3 {
+		t.Errorf("array snapshot = %+v, want one instance, one input and template revision 3", as)
+	}
+}
+
+func TestTakeSnapshotFailsOnDeploymentError(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.on("GET", "/api/deployments", 200, "["+deploymentJSON+"]")
+	api.on("GET", "/api/deployments/1/server_arrays?view=instance_detail", 500, "internal error")
+
+	snapshot, err := c.TakeSnapshot()
+	if err == nil {
+		t.Fatalf("TakeSnapshot() captured %d arrays, want an error", len(snapshot.Arrays))
+	}
+	if !strings.Contains(err.Error(), "could not snapshot arrays") {
+		t.Errorf("error = %q", err)
+	}
+}
+
+func TestTakeSnapshotBoundsAndSortsArrays(t *testing.T) {
+	api := &fakeAPI{routes: map[string]fakeResponse{}}
+	limit := &concurrencyLimit{next: api}
+	server := httptest.NewServer(limit)
+	defer server.Close()
+	c := Client{EndPoint: server.URL}
+
+	var arrays []string
+	for n := 12; n > 0; n-- {
+		name := fmt.Sprintf("array-%02d", n)
+		if n == 9 || n == 10 {
+			name = "dup"
+		}
+		arrays = append(arrays, fmt.Sprintf(`{"name":%q,"links":[{"rel":"self","href":"/api/server_arrays/%d"},`+
+			`{"rel":"deployment","href":"/api/deployments/1"},{"rel":"next_instance","href":"/api/clouds/1/instances/N%d"}]}`,
+			name, n, n))
+		api.on("GET", fmt.Sprintf("/api/server_arrays/%d/current_instances", n), 200, "[]")
+		api.on("GET", fmt.Sprintf("/api/clouds/1/instances/N%d/inputs", n), 200, "[]")
+	}
+	api.on("GET", "/api/deployments", 200, "["+deploymentJSON+"]")
+	api.on("GET", "/api/deployments/1/server_arrays?view=instance_detail", 200, "["+strings.Join(arrays, ",")+"]")
+	api.on("POST", "/api/tags/by_resource", 200, "[]")
+
+	snapshot, err := c.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("TakeSnapshot() error = %s", err)
+	}
+	if limit.most > DefaultSnapshotParallelism {
+		t.Errorf("%d requests in flight at once, want at most %d", limit.most, DefaultSnapshotParallelism)
+	}
+	var order []string
+	for _, as := range snapshot.Arrays {
+		order = append(order, as.Array.id())
+	}
+	want := []string{"/api/server_arrays/1", "/api/server_arrays/2", "/api/server_arrays/3", "/api/server_arrays/4",
+		"/api/server_arrays/5", "/api/server_arrays/6", "/api/server_arrays/7", "/api/server_arrays/8",
+		"/api/server_arrays/11", "/api/server_arrays/12", "/api/server_arrays/9", "/api/server_arrays/10"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("arrays in order %v, want them sorted by name and then numeric id %v", order, want)
+	}
+}
+
+// snapshotArrayFixture builds an array snapshot for the diff and round trip tests
+func snapshotArrayFixture(deployment, name string, id int, min, max string) ArraySnapshot {
+	as := ArraySnapshot{Deployment: deployment}
+	as.Array.Name = name
+	as.Array.State = "enabled"
+	as.Array.ElasticityParams.Bounds.MinCount = min
+	as.Array.ElasticityParams.Bounds.MaxCount = max
+	as.Array.Links = rsLinks{{Rel: "self", Href: fmt.Sprintf("/api/server_arrays/%d", id)}}
+	return as
+}
+
+func TestSaveAndLoadSnapshot(t *testing.T) {
+	snapshot := Snapshot{Version: SnapshotVersion, TakenAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Account: "123", Arrays: []ArraySnapshot{snapshotArrayFixture("d1", "web", 10, "1", "3")}}
+	snapshot.Arrays[0].Array.ArrayTags = tags{{Name: "team", Value: "web"}}
+	snapshot.Arrays[0].Inputs = Inputs{{Name: "PORT", Value: "text:80"}}
+	snapshot.Arrays[0].Template = ServerTemplate{Name: "web", Revision: 3}
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := SaveSnapshot(snapshot, path); err != nil {
+		t.Fatalf("SaveSnapshot() error = %s", err)
+	}
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("LoadSnapshot() error = %s", err)
+	}
+	if !reflect.DeepEqual(loaded, snapshot) {
+		t.Errorf("LoadSnapshot() = %+v, want %+v", loaded, snapshot)
+	}
+	if diff := DiffSnapshots(snapshot, loaded); len(diff) != 0 {
+		t.Errorf("DiffSnapshots() of a round trip = %+v, want no changes", diff)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{name: "newer version", data: `{"version":2}`, err: "unsupported version 2"},
+		{name: "no version", data: `{"arrays":[]}`, err: "unsupported version 0"},
+		{name: "not json", data: "arrays:", err: "could not parse snapshot file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snapshot.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadSnapshot(path); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("LoadSnapshot() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+	if _, err := LoadSnapshot(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadSnapshot() of a missing file did not fail")
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	kept := snapshotArrayFixture("d1", "web", 10, "1", "3")
+	kept.Array.ArrayTags = tags{{Name: "team", Value: "web"}, {Name: "old", Value: "x"}}
+	kept.Inputs = Inputs{{Name: "PORT", Value: "text:80"}}
+	kept.Template = ServerTemplate{Name: "web", Revision: 3}
+	removed := snapshotArrayFixture("d1", "batch", 11, "0", "1")
+	older := Snapshot{Arrays: []ArraySnapshot{kept, removed}}
+
+	changed := snapshotArrayFixture("d1", "web", 10, "2", "3")
+	changed.Array.State = "disabled"
+	changed.Array.ArrayTags = tags{{Name: "team", Value: "api"}}
+	changed.Inputs = Inputs{{Name: "PORT", Value: "text:8080"}, {Name: "ENV", Value: "text:prod"}}
+	changed.Template = ServerTemplate{Name: "web", Revision: 4}
+	changed.Instances = ServerInstances{{Name: "i1"}}
+	added := snapshotArrayFixture("d2", "queue", 12, "1", "1")
+	newer := Snapshot{Arrays: []ArraySnapshot{changed, added}}
+
+	var got []string
+	for _, row := range DiffSnapshots(older, newer).TableData() {
+		got = append(got, strings.Join(row, "|"))
+	}
+	want := []string{
+		"d1|web|state|enabled|disabled",
+		"d1|web|bounds|1-3|2-3",
+		"d1|web|template|web@3|web@4",
+		"d1|web|instances|0|1",
+		"d1|web|input ENV||text:prod",
+		"d1|web|input PORT|text:80|text:8080",
+		"d1|web|tag old|x|",
+		"d1|web|tag team|web|api",
+		"d2|queue|array added||/api/server_arrays/12",
+		"d1|batch|array removed|/api/server_arrays/11|",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("DiffSnapshots() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
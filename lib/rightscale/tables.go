@@ -0,0 +1,200 @@
+package rightscale
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angelamancini/SJP_Go_Packages/lib/report"
+	"github.com/pkg/errors"
+)
+
+// ArrayTable shows server arrays as a report.Table
+// Tags adds a column per tag name, the arrays need their tags populated with PopulateArrayTags
+// Templates maps server template hrefs to templates, arrays whose template is missing show the href
+type ArrayTable struct {
+	Arrays    ServerArrays
+	Tags      []string
+	Templates map[string]ServerTemplate
+}
+
+// InstanceTable shows server instances as a report.Table
+// Tags adds a column per tag name, the instances need their tags populated with PopulateInstanceTags
+// Now is the time ages are measured from, the zero value means time.Now()
+// Age reads like 3d 4h so it does not sort under report.Query, sort the instances by CreatedAt before building the table
+type InstanceTable struct {
+	Instances ServerInstances
+	Tags      []string
+	Now       time.Time
+}
+
+// Table returns a table of the arrays with a column for each of the named tags
+func (sa ServerArrays) Table(tagNames ...string) ArrayTable {
+	return ArrayTable{Arrays: sa, Tags: tagNames}
+}
+
+// TableHeaders returns the default headers for a list of arrays
+func (sa ServerArrays) TableHeaders() []string {
+	return sa.Table().TableHeaders()
+}
+
+// TableData returns the default rows for a list of arrays
+func (sa ServerArrays) TableData() [][]string {
+	return sa.Table().TableData()
+}
+
+// TableHeaders returns the headers for the array table
+func (at ArrayTable) TableHeaders() []string {
+	return append([]string{"Name", "State", "Instances", "Min", "Max", "Template"}, at.Tags...)
+}
+
+// TableData returns the rows for the array table
+func (at ArrayTable) TableData() [][]string {
+	var data [][]string
+	for _, a := range at.Arrays {
+		template := a.NextInstance.Links.LinkValue("server_template")
+		if t, ok := at.Templates[template]; ok {
+			template = templateLabel(t)
+		}
+		row := []string{
+			a.Name,
+			a.State,
+			strconv.Itoa(a.InstancesCount),
+			a.ElasticityParams.Bounds.MinCount,
+			a.ElasticityParams.Bounds.MaxCount,
+			template,
+		}
+		for _, name := range at.Tags {
+			row = append(row, a.ArrayTags.TagValue(name))
+		}
+		data = append(data, row)
+	}
+	return data
+}
+
+// ArrayTemplates looks up the next instance template of every array, requesting each template once
+// The result can be used as ArrayTable.Templates so the table shows template names and revisions
+func (c Client) ArrayTemplates(arrays ServerArrays) (map[string]ServerTemplate, error) {
+	templates := map[string]ServerTemplate{}
+	for _, a := range arrays {
+		href := a.NextInstance.Links.LinkValue("server_template")
+		if href == "" {
+			continue
+		}
+		if _, ok := templates[href]; ok {
+			continue
+		}
+		t, err := c.ServerTemplate(href)
+		if err != nil {
+			return nil, errors.Errorf("encountered error requesting template for array %s %s", a.Name, err)
+		}
+		templates[href] = t
+	}
+	return templates, nil
+}
+
+// Table returns a table of the instances with a column for each of the named tags
+func (si ServerInstances) Table(tagNames ...string) InstanceTable {
+	return InstanceTable{Instances: si, Tags: tagNames}
+}
+
+// TableHeaders returns the default headers for a list of instances
+func (si ServerInstances) TableHeaders() []string {
+	return si.Table().TableHeaders()
+}
+
+// TableData returns the default rows for a list of instances
+func (si ServerInstances) TableData() [][]string {
+	return si.Table().TableData()
+}
+
+// TableHeaders returns the headers for the instance table
+func (it InstanceTable) TableHeaders() []string {
+	return append([]string{"Name", "State", "Private IP", "Age"}, it.Tags...)
+}
+
+// TableData returns the rows for the instance table
+func (it InstanceTable) TableData() [][]string {
+	now := it.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	var data [][]string
+	for _, i := range it.Instances {
+		row := []string{i.Name, i.State, strings.Join(i.PrivateIPAddresses, ", "), instanceAge(i.CreatedAt, now)}
+		for _, name := range it.Tags {
+			row = append(row, i.InstanceTags.TagValue(name))
+		}
+		data = append(data, row)
+	}
+	return data
+}
+
+// InstanceStream lists the instances of every array as a report.Stream, fetching one array at a time
+// so rows can be printed as each array's instances arrive rather than once the whole account has been listed
+// The rows are those of InstanceTable with the array name in front, tags are fetched when tagNames are given
+func (c Client) InstanceStream(arrays ServerArrays, tagNames ...string) report.Stream {
+	headers := append([]string{"Array"}, InstanceTable{Tags: tagNames}.TableHeaders()...)
+	return report.NewStream(headers, func(emit func(row []string) error) error {
+		for _, a := range arrays {
+			arrayID, _ := a.ArrayID()
+			instances, err := c.GetArrayInstances(arrayID)
+			if err != nil {
+				return err
+			}
+			if len(tagNames) > 0 && len(instances) > 0 {
+				if instances, err = c.PopulateInstanceTags(instances); err != nil {
+					return err
+				}
+			}
+			for _, row := range instances.Table(tagNames...).TableData() {
+				if err := emit(append([]string{a.Name}, row...)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// TableHeaders returns the headers for a list of deployments
+func (d Deployments) TableHeaders() []string {
+	return []string{"Name", "Href"}
+}
+
+// TableData returns the rows for a list of deployments
+func (d Deployments) TableData() [][]string {
+	var data [][]string
+	for _, deployment := range d {
+		data = append(data, []string{deployment.Name, deployment.Links.LinkValue("self")})
+	}
+	return data
+}
+
+// TableHeaders returns the headers for a list of inputs
+func (i Inputs) TableHeaders() []string {
+	return []string{"Name", "Kind", "Value"}
+}
+
+// TableData returns the rows for a list of inputs
+func (i Inputs) TableData() [][]string {
+	var data [][]string
+	for _, input := range i {
+		data = append(data, []string{input.Name, input.Kind, input.Value})
+	}
+	return data
+}
+
+// instanceAge formats the time since created at as report.HumanizeDuration does e.g. 3d 4h, unparseable times are
+// shown as is
+func instanceAge(createdAt string, now time.Time) string {
+	created, err := time.Parse(createdAtFormat, createdAt)
+	if err != nil {
+		return createdAt
+	}
+	age := now.Sub(created)
+	if age < 0 {
+		age = 0
+	}
+	return report.HumanizeDuration(age)
+}
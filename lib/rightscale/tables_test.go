@@ -0,0 +1,131 @@
+package rightscale
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angelamancini/SJP_Go_Packages/lib/report"
+)
+
+// tableString joins a table's headers and rows for easy comparison
+func tableString(t report.Table) string {
+	lines := []string{strings.Join(t.TableHeaders(), ",")}
+	for _, row := range t.TableData() {
+		lines = append(lines, strings.Join(row, ","))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestArrayTable(t *testing.T) {
+	var a1 ServerArray
+	if err := json.Unmarshal([]byte(arrayJSON), &a1); err != nil {
+		t.Fatal(err)
+	}
+	a1.ArrayTags = tags{{Name: "team", Value: "web"}}
+	a2 := ServerArray{Name: "a2", State: "disabled"}
+	arrays := ServerArrays{a1, a2}
+
+	want := "Name,State,Instances,Min,Max,Template\n" +
+		"a1,enabled,2,1,3,/api/server_templates/5\n" +
+		"a2,disabled,0,,,"
+	if got := tableString(arrays); got != want {
+		t.Errorf("ServerArrays table =\n%s\nwant\n%s", got, want)
+	}
+
+	table := arrays.Table("team", "env")
+	table.Templates = map[string]ServerTemplate{"/api/server_templates/5": {Name: "web", Revision: 3}}
+	want = "Name,State,Instances,Min,Max,Template,team,env\n" +
+		"a1,enabled,2,1,3,web@3,web,N/A\n" +
+		"a2,disabled,0,,,,N/A,N/A"
+	if got := tableString(table); got != want {
+		t.Errorf("ArrayTable =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestArrayTemplates(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.on("GET", "/api/server_templates/5", 200, `{"name":"web","revision":3}`)
+	var a1 ServerArray
+	if err := json.Unmarshal([]byte(arrayJSON), &a1); err != nil {
+		t.Fatal(err)
+	}
+	a2 := a1
+	a2.Name = "a2"
+
+	templates, err := c.ArrayTemplates(ServerArrays{a1, a2, {Name: "no template"}})
+	if err != nil {
+		t.Fatalf("ArrayTemplates() error = %s", err)
+	}
+	if len(templates) != 1 || templateLabel(templates["/api/server_templates/5"]) != "web@3" {
+		t.Errorf("ArrayTemplates() = %+v, want web@3", templates)
+	}
+	if n := api.count("GET", "/api/server_templates/5"); n != 1 {
+		t.Errorf("template requested %d times, want once", n)
+	}
+
+	api.on("GET", "/api/server_templates/5", 500, "")
+	if _, err := c.ArrayTemplates(ServerArrays{a1}); err == nil || !strings.Contains(err.Error(), "array a1") {
+		t.Errorf("ArrayTemplates() error = %v, want the array named", err)
+	}
+}
+
+func TestInstanceTable(t *testing.T) {
+	now := time.Date(2020, 1, 5, 4, 0, 0, 0, time.UTC)
+	instances := ServerInstances{
+		{Name: "i1", State: "operational", PrivateIPAddresses: []string{"10.0.0.1", "10.0.0.2"},
+			CreatedAt: "2020/01/02 00:00:00 +0000", InstanceTags: tags{{Name: "team", Value: "web"}}},
+		{Name: "i2", State: "booting", CreatedAt: "2020/01/05 03:59:30 +0000"},
+		{Name: "i3", State: "pending", CreatedAt: "soon"},
+		{Name: "i4", State: "pending", CreatedAt: "2020/01/06 00:00:00 +0000"},
+	}
+	table := instances.Table("team")
+	table.Now = now
+	want := "Name,State,Private IP,Age,team\n" +
+		"i1,operational,10.0.0.1, 10.0.0.2,3d 4h,web\n" +
+		"i2,booting,,30s,N/A\n" +
+		//unparseable times are shown as they are and clock skew does not give negative ages
+		"i3,pending,,soon,N/A\n" +
+		"i4,pending,,0s,N/A"
+	if got := tableString(table); got != want {
+		t.Errorf("InstanceTable =\n%s\nwant\n%s", got, want)
+	}
+	if headers := strings.Join(instances.TableHeaders(), ","); headers != "Name,State,Private IP,Age" {
+		t.Errorf("ServerInstances headers = %q", headers)
+	}
+}
+
+func TestInstanceStream(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.on("GET", "/api/server_arrays/10/current_instances", 200,
+		`[{"name":"i1","state":"operational","links":[{"rel":"self","href":"/api/clouds/1/instances/1"}]}]`)
+	api.on("POST", "/api/tags/by_resource", 200, `[{"links":[{"rel":"resource","href":"/api/clouds/1/instances/1"}],`+
+		`"tags":[{"name":"ec2:team=web"}]}]`)
+	arrays := ServerArrays{{Name: "a1", Links: rsLinks{{Rel: "self", Href: "/api/server_arrays/10"}}}}
+
+	table, err := report.Collect(c.InstanceStream(arrays, "team"))
+	if err != nil {
+		t.Fatalf("Collect() error = %s", err)
+	}
+	want := "Array,Name,State,Private IP,Age,team\na1,i1,operational,,,web"
+	if got := tableString(table); got != want {
+		t.Errorf("InstanceStream =\n%s\nwant\n%s", got, want)
+	}
+
+	api.on("GET", "/api/server_arrays/10/current_instances", 500, "")
+	if _, err := report.Collect(c.InstanceStream(arrays)); err == nil {
+		t.Error("InstanceStream() did not return the request error")
+	}
+}
+
+func TestDeploymentAndInputTables(t *testing.T) {
+	deployments := Deployments{{Name: "d1", Links: rsLinks{{Rel: "self", Href: "/api/deployments/1"}}}}
+	if got := tableString(deployments); got != "Name,Href\nd1,/api/deployments/1" {
+		t.Errorf("Deployments table = %q", got)
+	}
+	inputs := Inputs{{Name: "PORT", Kind: "text", Value: "80"}}
+	if got := tableString(inputs); got != "Name,Kind,Value\nPORT,text,80" {
+		t.Errorf("Inputs table = %q", got)
+	}
+}
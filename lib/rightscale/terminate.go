@@ -0,0 +1,181 @@
+package rightscale
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTerminateParallelism is the number of terminate calls made at once when no parallelism is given
+const DefaultTerminateParallelism = 5
+
+// TerminateStatus is the outcome of terminating a single instance
+type TerminateStatus string
+
+// Possible outcomes of terminating a single instance
+const (
+	TerminateSucceeded         TerminateStatus = "terminated"
+	TerminateAlreadyTerminated TerminateStatus = "already terminated"
+	TerminateNotFound          TerminateStatus = "not found"
+	TerminateFailed            TerminateStatus = "failed"
+)
+
+// ErrInstanceNotFound is the cause of a TerminateNotFound result
+var ErrInstanceNotFound = errors.New("instance not found")
+
+// ErrAlreadyTerminated is the cause of a TerminateAlreadyTerminated result
+var ErrAlreadyTerminated = errors.New("instance already terminated")
+
+// TerminateError is the cause of a TerminateFailed result when Rightscale answered with an unexpected status code
+type TerminateError struct {
+	Href       string
+	StatusCode int
+	Body       string
+}
+
+// Error implements the error interface
+func (e *TerminateError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("terminate instance %s expected 204 got %d", e.Href, e.StatusCode)
+	}
+	return fmt.Sprintf("terminate instance %s expected 204 got %d - %s", e.Href, e.StatusCode, e.Body)
+}
+
+// TerminateResult is the outcome of terminating a single instance
+// Err is nil on success, ErrAlreadyTerminated, ErrInstanceNotFound, a *TerminateError or a network error otherwise
+type TerminateResult struct {
+	Href   string
+	Status TerminateStatus
+	Err    error
+}
+
+// TerminateResults is the outcome of a batch of terminations, it is in the same order as the hrefs passed in
+// it implements the report.Table interface
+type TerminateResults []TerminateResult
+
+// TerminateInstancesDetailed terminates instances contained within the instanceHref slice and returns a result
+// per instance. At most parallelism terminate calls are in flight at once, values below 1 use DefaultTerminateParallelism
+func (c Client) TerminateInstancesDetailed(instanceHrefs []string, parallelism int) TerminateResults {
+	if parallelism < 1 {
+		parallelism = DefaultTerminateParallelism
+	}
+	results := make(TerminateResults, len(instanceHrefs))
+	if err := c.checkRoles("terminate instances"); err != nil {
+		for n, href := range instanceHrefs {
+			results[n] = TerminateResult{Href: href, Status: TerminateFailed, Err: err}
+		}
+		return results
+	}
+	slots := make(chan struct{}, parallelism)
+	var loopGroup sync.WaitGroup
+	for n, href := range instanceHrefs {
+		loopGroup.Add(1)
+		slots <- struct{}{}
+		go func(n int, href string) {
+			defer loopGroup.Done()
+			results[n] = c.terminateInstance(href)
+			<-slots
+		}(n, href)
+	}
+	loopGroup.Wait()
+	return results
+}
+
+// terminateInstance makes a single terminate call and classifies the response
+func (c Client) terminateInstance(href string) TerminateResult {
+	c.log().Info("terminating instance", "href", href)
+	path := fmt.Sprintf("%s/%s", href, "terminate")
+	instanceTerminateParams := RequestParams{"POST", path, nil}
+	resp, err := c.RequestDetailed(instanceTerminateParams)
+	if err != nil {
+		return TerminateResult{Href: href, Status: TerminateFailed,
+			Err: errors.WithMessagef(err, "Error calling terminate instance endpoint for %s", href)}
+	}
+	switch resp.StatusCode {
+	case 200, 202, 204:
+		return TerminateResult{Href: href, Status: TerminateSucceeded}
+	case 404:
+		return TerminateResult{Href: href, Status: TerminateNotFound, Err: ErrInstanceNotFound}
+	}
+	responseBody, _ := ioutil.ReadAll(resp.Body)
+	body := strings.TrimSpace(string(responseBody))
+	//Rightscale answers 422 when the instance is not in a state that can be terminated, which may be because it is
+	//already going away or because e.g. it is locked, so the instance's state decides which
+	if resp.StatusCode == 422 {
+		if state, err := c.instanceState(href); err == nil && goneStates[state] {
+			return TerminateResult{Href: href, Status: TerminateAlreadyTerminated, Err: ErrAlreadyTerminated}
+		}
+	}
+	return TerminateResult{Href: href, Status: TerminateFailed,
+		Err: &TerminateError{Href: href, StatusCode: resp.StatusCode, Body: body}}
+}
+
+// goneStates are the instance states in which a terminate call has nothing left to do
+var goneStates = map[string]bool{
+	"terminated":      true,
+	"decommissioning": true,
+	"terminating":     true,
+}
+
+// instanceState returns the current state of the instance at href, bypassing any cache
+func (c Client) instanceState(href string) (string, error) {
+	data, err := c.Uncached().Request(RequestParams{method: "GET", url: href})
+	if err != nil {
+		return "", err
+	}
+	var instance ServerInstance
+	err = json.Unmarshal(data, &instance)
+	if err != nil {
+		return "", errors.WithMessage(err, "could not unmarshal Instance response")
+	}
+	return instance.State, nil
+}
+
+// Failed returns the hrefs of instances which failed to terminate and may be retried
+// Instances which were not found or already terminated are not included
+func (tr TerminateResults) Failed() []string {
+	var hrefs []string
+	for _, r := range tr {
+		if r.Status == TerminateFailed {
+			hrefs = append(hrefs, r.Href)
+		}
+	}
+	return hrefs
+}
+
+// Err returns a single error describing every failed or missing instance, or nil when every
+// instance was terminated or was already terminated
+func (tr TerminateResults) Err() error {
+	var loopErrors []string
+	for _, r := range tr {
+		if r.Status == TerminateFailed || r.Status == TerminateNotFound {
+			loopErrors = append(loopErrors, fmt.Sprintf("%s: %s", r.Href, r.Err))
+		}
+	}
+	if len(loopErrors) != 0 {
+		return errors.Errorf("%d of %d instances failed to terminate - %s", len(loopErrors), len(tr), strings.Join(loopErrors, "; "))
+	}
+	return nil
+}
+
+// TableHeaders returns the headers for a list of terminate results
+func (tr TerminateResults) TableHeaders() []string {
+	return []string{"Href", "Status", "Error"}
+}
+
+// TableData returns the rows for a list of terminate results
+func (tr TerminateResults) TableData() [][]string {
+	var data [][]string
+	for _, r := range tr {
+		var cause string
+		if r.Err != nil {
+			cause = r.Err.Error()
+		}
+		data = append(data, []string{r.Href, string(r.Status), cause})
+	}
+	return data
+}
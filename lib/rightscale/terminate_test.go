@@ -0,0 +1,57 @@
+package rightscale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTerminateInstancesDetailed(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.on("POST", "/api/clouds/1/instances/OK/terminate", 204, "")
+	api.on("POST", "/api/clouds/1/instances/GONE/terminate", 422, "instance is already terminated")
+	api.on("GET", "/api/clouds/1/instances/GONE", 200, `{"name":"gone","state":"decommissioning"}`)
+	api.on("POST", "/api/clouds/1/instances/LOCKED/terminate", 422, "instance is locked and cannot be terminated")
+	api.on("GET", "/api/clouds/1/instances/LOCKED", 200, `{"name":"locked","state":"operational"}`)
+	api.on("POST", "/api/clouds/1/instances/UNKNOWN/terminate", 422, "cannot be terminated")
+
+	hrefs := []string{
+		"/api/clouds/1/instances/OK",
+		"/api/clouds/1/instances/GONE",
+		"/api/clouds/1/instances/LOCKED",
+		"/api/clouds/1/instances/UNKNOWN",
+		"/api/clouds/1/instances/MISSING",
+	}
+	want := []TerminateStatus{TerminateSucceeded, TerminateAlreadyTerminated, TerminateFailed, TerminateFailed, TerminateNotFound}
+	results := c.TerminateInstancesDetailed(hrefs, 2)
+	for n, r := range results {
+		if r.Href != hrefs[n] || r.Status != want[n] {
+			t.Errorf("result %d = %s %s, want %s %s", n, r.Href, r.Status, hrefs[n], want[n])
+		}
+	}
+	failed := results.Failed()
+	if len(failed) != 2 || failed[0] != hrefs[2] || failed[1] != hrefs[3] {
+		t.Errorf("Failed() = %v, want the locked and unknown instances", failed)
+	}
+	if results.Err() == nil {
+		t.Error("Err() = nil, want the locked, unknown and missing instances reported")
+	}
+	if c.TerminateInstancesDetailed(hrefs[:2], 0).Err() != nil {
+		t.Error("Err() reported terminated and already terminated instances as failures")
+	}
+}
+
+func TestTerminateChecksCurrentStateWithCache(t *testing.T) {
+	api, c := newFakeAPI(t)
+	c = c.WithCache(CacheOptions{DefaultTTL: time.Hour})
+	api.onSequence("GET", "/api/clouds/1/instances/GONE", 200,
+		`{"name":"gone","state":"operational"}`, `{"name":"gone","state":"terminating"}`)
+	api.on("POST", "/api/clouds/1/instances/GONE/terminate", 422, "instance is already terminated")
+	if _, err := c.Instance("1", "GONE"); err != nil {
+		t.Fatalf("Instance() error = %s", err)
+	}
+
+	results := c.TerminateInstancesDetailed([]string{"/api/clouds/1/instances/GONE"}, 1)
+	if results[0].Status != TerminateAlreadyTerminated {
+		t.Errorf("status = %s, want the 422 checked against the instance's current state", results[0].Status)
+	}
+}
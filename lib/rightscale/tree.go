@@ -0,0 +1,143 @@
+package rightscale
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/angelamancini/SJP_Go_Packages/lib/report"
+	"github.com/pkg/errors"
+)
+
+// DefaultTreeParallelism is the number of arrays DeploymentTree lists the instances of at once
+const DefaultTreeParallelism = 5
+
+// treeHeaders are the columns shown next to every node of a deployment tree
+var treeHeaders = []string{"State", "Instances", "Private IP"}
+
+// DeploymentTree arranges deployments, arrays and instances into a report.Tree using their links
+// Arrays sit under the deployment their deployment link points at and instances under the array their parent link
+// points at. Arrays and instances whose parent is not in the lists are kept under an unknown node rather than dropped
+func DeploymentTree(deployments Deployments, arrays ServerArrays, instances ServerInstances) report.Tree {
+	tree := report.Tree{Headers: treeHeaders}
+
+	instancesByArray := map[string]ServerInstances{}
+	for _, i := range instances {
+		parent := i.Links.LinkValue("parent")
+		instancesByArray[parent] = append(instancesByArray[parent], i)
+	}
+	arraysByDeployment := map[string]ServerArrays{}
+	for _, a := range arrays {
+		parent := a.Links.LinkValue("deployment")
+		arraysByDeployment[parent] = append(arraysByDeployment[parent], a)
+	}
+
+	addArrays := func(node *report.Node, arrays ServerArrays) {
+		for _, a := range arrays {
+			arrayNode := node.Add(a.Name, a.State, strconv.Itoa(a.InstancesCount))
+			for _, i := range instancesByArray[a.id()] {
+				arrayNode.Add(i.Name, i.State, "", strings.Join(i.PrivateIPAddresses, ", "))
+			}
+		}
+	}
+	knownDeployments := map[string]bool{}
+	for _, d := range deployments {
+		knownDeployments[d.Links.LinkValue("self")] = true
+		deploymentArrays := arraysByDeployment[d.Links.LinkValue("self")]
+		node := tree.Add(d.Name, "", strconv.Itoa(instanceCount(deploymentArrays)))
+		addArrays(node, deploymentArrays)
+	}
+
+	var strayArrays ServerArrays
+	for _, a := range arrays {
+		if !knownDeployments[a.Links.LinkValue("deployment")] {
+			strayArrays = append(strayArrays, a)
+		}
+	}
+	if len(strayArrays) > 0 {
+		addArrays(tree.Add("(unknown deployment)", "", strconv.Itoa(instanceCount(strayArrays))), strayArrays)
+	}
+	knownArrays := map[string]bool{}
+	for _, a := range arrays {
+		if a.id() != "" {
+			knownArrays[a.id()] = true
+		}
+	}
+	var strayInstances ServerInstances
+	for _, i := range instances {
+		if !knownArrays[i.Links.LinkValue("parent")] {
+			strayInstances = append(strayInstances, i)
+		}
+	}
+	if len(strayInstances) > 0 {
+		node := tree.Add("(unknown array)", "", strconv.Itoa(len(strayInstances)))
+		for _, i := range strayInstances {
+			node.Add(i.Name, i.State, "", strings.Join(i.PrivateIPAddresses, ", "))
+		}
+	}
+	return tree
+}
+
+// DeploymentTree lists the account's deployments, arrays and their current instances and arranges them as a tree
+// At most DefaultTreeParallelism arrays have their instances listed at once, any failure fails the whole tree
+func (c Client) DeploymentTree() (report.Tree, error) {
+	deployments, err := c.GetDeployments()
+	if err != nil {
+		return report.Tree{}, errors.Errorf("encountered error requesting deployments %s", err)
+	}
+	arrays, err := c.Arrays()
+	if err != nil {
+		return report.Tree{}, errors.Errorf("encountered error requesting arrays %s", err)
+	}
+	//each array's instances are kept in its own slot so the tree is built in array order whatever order they arrive in
+	arrayInstances := make([]ServerInstances, len(arrays))
+	var mu sync.Mutex
+	var loopErrors []error
+	slots := make(chan struct{}, DefaultTreeParallelism)
+	var loopGroup sync.WaitGroup
+	for n, a := range arrays {
+		loopGroup.Add(1)
+		slots <- struct{}{}
+		go func(n int, a ServerArray) {
+			defer loopGroup.Done()
+			arrayID, _ := a.ArrayID()
+			instances, err := c.GetArrayInstances(arrayID)
+			<-slots
+			if err != nil {
+				mu.Lock()
+				loopErrors = append(loopErrors, errors.Errorf("array %s - %s", a.Name, err))
+				mu.Unlock()
+				return
+			}
+			arrayInstances[n] = instances
+		}(n, a)
+	}
+	loopGroup.Wait()
+	if len(loopErrors) != 0 {
+		return report.Tree{}, errors.Errorf("could not list instances of %d arrays, first error %s", len(loopErrors), loopErrors[0])
+	}
+	var instances ServerInstances
+	for n, a := range arrays {
+		//instances listed through their array always belong to it, whatever their own links say
+		for _, i := range arrayInstances[n] {
+			var links rsLinks
+			for _, l := range i.Links {
+				if l.Rel != "parent" {
+					links = append(links, l)
+				}
+			}
+			i.Links = append(links, rsLink{Rel: "parent", Href: a.id()})
+			instances = append(instances, i)
+		}
+	}
+	return DeploymentTree(deployments, arrays, instances), nil
+}
+
+// instanceCount adds up the instance counts of a list of arrays
+func instanceCount(arrays ServerArrays) int {
+	count := 0
+	for _, a := range arrays {
+		count += a.InstancesCount
+	}
+	return count
+}
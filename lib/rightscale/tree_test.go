@@ -0,0 +1,101 @@
+package rightscale
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDeploymentTree(t *testing.T) {
+	deployments := Deployments{{Name: "d1", Links: rsLinks{{Rel: "self", Href: "/api/deployments/1"}}}}
+	arrays := ServerArrays{
+		{Name: "a1", State: "enabled", InstancesCount: 1, Links: rsLinks{{Rel: "self", Href: "/api/server_arrays/10"},
+			{Rel: "deployment", Href: "/api/deployments/1"}}},
+		{Name: "a2", State: "disabled", Links: rsLinks{{Rel: "self", Href: "/api/server_arrays/11"},
+			{Rel: "deployment", Href: "/api/deployments/9"}}},
+	}
+	instances := ServerInstances{
+		{Name: "i1", State: "operational", PrivateIPAddresses: []string{"10.0.0.1"},
+			Links: rsLinks{{Rel: "parent", Href: "/api/server_arrays/10"}}},
+		{Name: "i2", State: "booting", Links: rsLinks{{Rel: "parent", Href: "/api/server_arrays/99"}}},
+	}
+	want := [][]string{
+		{"d1", "", "1", ""},
+		{"└── a1", "enabled", "1", ""},
+		{"    └── i1", "operational", "", "10.0.0.1"},
+		{"(unknown deployment)", "", "0", ""},
+		{"└── a2", "disabled", "0", ""},
+		{"(unknown array)", "", "1", ""},
+		{"└── i2", "booting", "", ""},
+	}
+	got := DeploymentTree(deployments, arrays, instances).TableData()
+	if len(got) != len(want) {
+		t.Fatalf("DeploymentTree() has %d rows, want %d: %q", len(got), len(want), got)
+	}
+	for n := range want {
+		if strings.Join(got[n], "|") != strings.Join(want[n], "|") {
+			t.Errorf("row %d = %q, want %q", n, got[n], want[n])
+		}
+	}
+}
+
+func TestClientDeploymentTree(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.serveDeployment("")
+	api.on("GET", "/api/server_arrays/10/current_instances", 200,
+		`[{"name":"i1","state":"operational","links":[{"rel":"parent","href":"/api/server_arrays/77"}]}]`)
+
+	tree, err := c.DeploymentTree()
+	if err != nil {
+		t.Fatalf("DeploymentTree() error = %s", err)
+	}
+	var labels []string
+	for _, row := range tree.TableData() {
+		labels = append(labels, row[0])
+	}
+	want := "d1|└── a1|    └── i1"
+	if strings.Join(labels, "|") != want {
+		t.Errorf("DeploymentTree() = %q, want %q", labels, want)
+	}
+}
+
+func TestClientDeploymentTreeListsArraysInParallel(t *testing.T) {
+	api := &fakeAPI{routes: map[string]fakeResponse{}}
+	limit := &concurrencyLimit{next: api}
+	server := httptest.NewServer(limit)
+	defer server.Close()
+	c := Client{EndPoint: server.URL}
+
+	var arrays, want []string
+	for n := 1; n <= 12; n++ {
+		arrays = append(arrays, fmt.Sprintf(`{"name":"a%02d","links":[{"rel":"self","href":"/api/server_arrays/%d"},`+
+			`{"rel":"deployment","href":"/api/deployments/1"}]}`, n, n))
+		api.on("GET", fmt.Sprintf("/api/server_arrays/%d/current_instances", n), 200, fmt.Sprintf(`[{"name":"i%02d"}]`, n))
+		want = append(want, fmt.Sprintf("a%02d", n), fmt.Sprintf("i%02d", n))
+	}
+	api.on("GET", "/api/deployments", 200, "["+deploymentJSON+"]")
+	api.on("GET", "/api/deployments/1/server_arrays?view=instance_detail", 200, "["+strings.Join(arrays, ",")+"]")
+
+	tree, err := c.DeploymentTree()
+	if err != nil {
+		t.Fatalf("DeploymentTree() error = %s", err)
+	}
+	//instances stay under their own array whichever request finishes first
+	var got []string
+	for _, row := range tree.TableData()[1:] {
+		got = append(got, strings.TrimLeft(row[0], "├└│─ "))
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("DeploymentTree() = %q, want %q", got, want)
+	}
+	if limit.most > DefaultTreeParallelism {
+		t.Errorf("%d requests were in flight at once, want at most %d", limit.most, DefaultTreeParallelism)
+	}
+
+	api.on("GET", "/api/server_arrays/3/current_instances", 500, "")
+	api.on("GET", "/api/server_arrays/7/current_instances", 500, "")
+	if _, err := c.DeploymentTree(); err == nil || !strings.Contains(err.Error(), "could not list instances of 2 arrays") {
+		t.Errorf("DeploymentTree() error = %v, want both failed arrays counted", err)
+	}
+}
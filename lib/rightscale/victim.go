@@ -0,0 +1,209 @@
+package rightscale
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DoNotTerminateTag is the instance tag which protects an instance from being picked for downscale
+// any value other than false protects the instance
+const DoNotTerminateTag = "do_not_terminate"
+
+// createdAtFormat is the date format Rightscale uses for created_at e.g. 2012/12/24 13:27:58 +0000
+const createdAtFormat = "2006/01/02 15:04:05 -0700"
+
+// Victim is an instance picked for termination along with the reason it was picked
+type Victim struct {
+	Instance ServerInstance
+	Reason   string
+}
+
+// Victims is a collection of Victim, it implements the report.Table interface
+type Victims []Victim
+
+// TableHeaders returns the headers for a list of victims
+func (v Victims) TableHeaders() []string {
+	return []string{"Name", "Href", "State", "Created At", "Reason"}
+}
+
+// TableData returns the rows for a list of victims
+func (v Victims) TableData() [][]string {
+	var data [][]string
+	for _, victim := range v {
+		i := victim.Instance
+		data = append(data, []string{i.Name, i.id(), i.State, i.CreatedAt, victim.Reason})
+	}
+	return data
+}
+
+// VictimStrategy picks count instances to terminate from a list of running instances
+// A strategy may return fewer than count victims when not enough instances are eligible
+type VictimStrategy interface {
+	SelectVictims(instances ServerInstances, count int) (Victims, error)
+}
+
+// VictimStrategyFunc allows an ordinary function to be used as a VictimStrategy
+type VictimStrategyFunc func(instances ServerInstances, count int) (Victims, error)
+
+// SelectVictims calls f(instances, count)
+func (f VictimStrategyFunc) SelectVictims(instances ServerInstances, count int) (Victims, error) {
+	return f(instances, count)
+}
+
+// OldestFirst picks the instances with the earliest created at time
+func OldestFirst() VictimStrategy {
+	return VictimStrategyFunc(func(instances ServerInstances, count int) (Victims, error) {
+		sorted, err := sortByCreatedAt(instances)
+		if err != nil {
+			return nil, err
+		}
+		return pick(sorted, count, "oldest"), nil
+	})
+}
+
+// NewestFirst picks the instances with the latest created at time
+func NewestFirst() VictimStrategy {
+	return VictimStrategyFunc(func(instances ServerInstances, count int) (Victims, error) {
+		sorted, err := sortByCreatedAt(instances)
+		if err != nil {
+			return nil, err
+		}
+		for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+			sorted[i], sorted[j] = sorted[j], sorted[i]
+		}
+		return pick(sorted, count, "newest"), nil
+	})
+}
+
+// DatacenterBalanced picks instances from whichever datacenter currently has the most instances,
+// so the array stays spread evenly after downscale. Ties are broken by datacenter href and the
+// oldest instance in the chosen datacenter is picked
+func DatacenterBalanced() VictimStrategy {
+	return VictimStrategyFunc(func(instances ServerInstances, count int) (Victims, error) {
+		sorted, err := sortByCreatedAt(instances)
+		if err != nil {
+			return nil, err
+		}
+		byDatacenter := map[string]ServerInstances{}
+		var datacenters []string
+		for _, i := range sorted {
+			dc := i.Links.LinkValue("datacenter")
+			if _, ok := byDatacenter[dc]; !ok {
+				datacenters = append(datacenters, dc)
+			}
+			byDatacenter[dc] = append(byDatacenter[dc], i)
+		}
+		sort.Strings(datacenters)
+		var victims Victims
+		for len(victims) < count {
+			var busiest string
+			for _, dc := range datacenters {
+				if len(byDatacenter[dc]) > len(byDatacenter[busiest]) {
+					busiest = dc
+				}
+			}
+			remaining := byDatacenter[busiest]
+			if len(remaining) == 0 {
+				break
+			}
+			victims = append(victims, Victim{
+				Instance: remaining[0],
+				Reason:   fmt.Sprintf("oldest of %d instances in datacenter %s, the most of any datacenter", len(remaining), datacenterLabel(busiest)),
+			})
+			byDatacenter[busiest] = remaining[1:]
+		}
+		return victims, nil
+	})
+}
+
+// SkipProtected removes locked instances, instances which are not operational and instances carrying any of the
+// protectTags before handing the rest to next. A protect tag protects an instance unless its value is false
+func SkipProtected(next VictimStrategy, protectTags ...string) VictimStrategy {
+	return VictimStrategyFunc(func(instances ServerInstances, count int) (Victims, error) {
+		var eligible ServerInstances
+		for _, i := range instances {
+			if i.Locked || i.State != "operational" || protected(i, protectTags) {
+				continue
+			}
+			eligible = append(eligible, i)
+		}
+		victims, err := next.SelectVictims(eligible, count)
+		if err != nil {
+			return nil, err
+		}
+		skipped := len(instances) - len(eligible)
+		if skipped > 0 {
+			for i := range victims {
+				victims[i].Reason = fmt.Sprintf("%s (%d protected instances skipped)", victims[i].Reason, skipped)
+			}
+		}
+		return victims, nil
+	})
+}
+
+// protected reports whether an instance carries any of the given protect tags
+func protected(i ServerInstance, protectTags []string) bool {
+	for _, name := range protectTags {
+		for _, t := range i.InstanceTags {
+			if t.Name == name && !strings.EqualFold(t.Value, "false") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sortByCreatedAt returns a copy of instances sorted oldest first
+// instances created in the same second are ordered by href so the result is stable between runs
+func sortByCreatedAt(instances ServerInstances) (ServerInstances, error) {
+	type dated struct {
+		instance  ServerInstance
+		createdAt time.Time
+	}
+	var list []dated
+	for _, i := range instances {
+		t, err := time.Parse(createdAtFormat, i.CreatedAt)
+		if err != nil {
+			return nil, errors.Errorf("could not parse created at %q for instance %s", i.CreatedAt, i.Name)
+		}
+		list = append(list, dated{instance: i, createdAt: t})
+	}
+	sort.SliceStable(list, func(a, b int) bool {
+		if list[a].createdAt.Equal(list[b].createdAt) {
+			return list[a].instance.id() < list[b].instance.id()
+		}
+		return list[a].createdAt.Before(list[b].createdAt)
+	})
+	var sorted ServerInstances
+	for _, d := range list {
+		sorted = append(sorted, d.instance)
+	}
+	return sorted, nil
+}
+
+// pick returns the first count instances as victims
+func pick(sorted ServerInstances, count int, adjective string) Victims {
+	var victims Victims
+	for n, i := range sorted {
+		if n >= count {
+			break
+		}
+		victims = append(victims, Victim{
+			Instance: i,
+			Reason:   fmt.Sprintf("%s instance, created %s (%d of %d)", adjective, i.CreatedAt, n+1, len(sorted)),
+		})
+	}
+	return victims
+}
+
+// datacenterLabel formats a datacenter href for a victim reason
+func datacenterLabel(href string) string {
+	if href == "" {
+		return "(unknown)"
+	}
+	return href
+}
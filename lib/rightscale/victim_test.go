@@ -0,0 +1,120 @@
+package rightscale
+
+import (
+	"strings"
+	"testing"
+)
+
+// victimInstance builds an instance for victim selection tests
+func victimInstance(name, state, createdAt, datacenter string, t ...tag) ServerInstance {
+	return ServerInstance{Name: name, State: state, CreatedAt: createdAt, InstanceTags: t,
+		Links: rsLinks{{Rel: "self", Href: "/api/clouds/1/instances/" + name}, {Rel: "datacenter", Href: datacenter}}}
+}
+
+func victimNames(victims Victims) string {
+	var names []string
+	for _, v := range victims {
+		names = append(names, v.Instance.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestVictimStrategies(t *testing.T) {
+	instances := ServerInstances{
+		victimInstance("b", "operational", "2020/01/02 00:00:00 +0000", "dc1"),
+		victimInstance("a", "operational", "2020/01/01 00:00:00 +0000", "dc1"),
+		victimInstance("d", "operational", "2020/01/04 00:00:00 +0000", "dc2"),
+		victimInstance("c", "operational", "2020/01/01 00:00:00 +0000", "dc1"),
+		victimInstance("e", "booting", "2019/12/31 00:00:00 +0000", "dc2"),
+		victimInstance("f", "operational", "2019/12/30 00:00:00 +0000", "dc2", tag{Name: DoNotTerminateTag, Value: "true"}),
+		victimInstance("g", "operational", "2019/12/29 00:00:00 +0000", "dc2", tag{Name: DoNotTerminateTag, Value: "false"}),
+	}
+	locked := victimInstance("h", "operational", "2019/12/28 00:00:00 +0000", "dc2")
+	locked.Locked = true
+	instances = append(instances, locked)
+
+	tests := []struct {
+		name     string
+		strategy VictimStrategy
+		count    int
+		want     string
+	}{
+		{name: "oldest first", strategy: OldestFirst(), count: 3, want: "h,g,f"},
+		{name: "newest first", strategy: NewestFirst(), count: 2, want: "d,b"},
+		{name: "skip protected", strategy: SkipProtected(OldestFirst(), DoNotTerminateTag), count: 3, want: "g,a,c"},
+		{name: "fewer eligible than count", strategy: SkipProtected(OldestFirst(), DoNotTerminateTag), count: 9, want: "g,a,c,b,d"},
+		{name: "datacenter balanced", strategy: SkipProtected(DatacenterBalanced(), DoNotTerminateTag), count: 3, want: "a,c,g"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			victims, err := tt.strategy.SelectVictims(instances, tt.count)
+			if err != nil {
+				t.Fatalf("SelectVictims() error = %s", err)
+			}
+			if got := victimNames(victims); got != tt.want {
+				t.Errorf("SelectVictims() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVictimStrategyBadCreatedAt(t *testing.T) {
+	_, err := OldestFirst().SelectVictims(ServerInstances{victimInstance("a", "operational", "yesterday", "")}, 1)
+	if err == nil {
+		t.Fatal("SelectVictims() error = nil, want an unparseable created at reported")
+	}
+}
+
+func TestExtractRSEC2Tag(t *testing.T) {
+	tests := []struct {
+		tag   string
+		key   string
+		value string
+		err   bool
+	}{
+		{tag: "ec2:Name=web", key: "Name", value: "web"},
+		{tag: "ec2:aws:autoscaling:groupName=x", key: "aws:autoscaling:groupName", value: "x"},
+		{tag: "ec2:url=http://x?a=b", key: "url", value: "http://x?a=b"},
+		{tag: "ec2:Empty=", key: "Empty", value: ""},
+		{tag: "ec2:flag", err: true},
+		{tag: "ec2", err: true},
+		{tag: "rs_monitoring:state=active", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			key, value, err := extractRSEC2Tag(tt.tag)
+			if tt.err {
+				if err == nil {
+					t.Errorf("extractRSEC2Tag() = %q, %q, want an error", key, value)
+				}
+				return
+			}
+			if err != nil || key != tt.key || value != tt.value {
+				t.Errorf("extractRSEC2Tag() = %q, %q, %v, want %q, %q", key, value, err, tt.key, tt.value)
+			}
+		})
+	}
+}
+
+func TestDownscaleDryRunWithMalformedTags(t *testing.T) {
+	api, c := newFakeAPI(t)
+	api.on("GET", "/api/server_arrays/10/current_instances", 200, `[`+
+		`{"name":"old","state":"operational","created_at":"2020/01/01 00:00:00 +0000",`+
+		`"links":[{"rel":"self","href":"/api/clouds/1/instances/OLD"}]},`+
+		`{"name":"new","state":"operational","created_at":"2020/01/02 00:00:00 +0000",`+
+		`"links":[{"rel":"self","href":"/api/clouds/1/instances/NEW"}]}]`)
+	api.on("POST", "/api/tags/by_resource", 200, `[`+
+		`{"tags":[{"name":"ec2:flag"},{"name":"ec2:`+DoNotTerminateTag+`=true"}],`+
+		`"links":[{"rel":"resource","href":"/api/clouds/1/instances/OLD"}]},`+
+		`{"tags":[{"name":"ec2:url=http://x"}],"links":[{"rel":"resource","href":"/api/clouds/1/instances/NEW"}]}]`)
+	array := ServerArray{Name: "a1", Links: rsLinks{{Rel: "self", Href: "/api/server_arrays/10"}}}
+
+	victims, err := c.DownscaleArrayInstancesDryRun(array, 1)
+	if err != nil {
+		t.Fatalf("DownscaleArrayInstancesDryRun() error = %s", err)
+	}
+	//the protected instance keeps its tag despite the malformed one next to it
+	if got := victimNames(victims); got != "new" {
+		t.Errorf("victims = %q, want new", got)
+	}
+}
@@ -0,0 +1,194 @@
+package rightscale
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrWaitTimeout is the cause of a WaitError when the wanted state was not reached in time
+var ErrWaitTimeout = errors.New("timed out waiting for state")
+
+// ErrTerminalState is the cause of a WaitError when an instance reached a state the wanted state cannot follow
+var ErrTerminalState = errors.New("instance reached a terminal state")
+
+// goingAway are the states which can only follow an instance being terminated
+var goingAway = []string{"decommissioning", "terminating", "terminated"}
+
+// laterStates lists the only states which may follow the states an instance cannot recover from
+// e.g. a stranded instance never becomes operational but it can still be terminated
+var laterStates = map[string][]string{
+	"stranded":            goingAway,
+	"stranded in booting": goingAway,
+	"decommissioning":     goingAway[1:],
+	"terminating":         goingAway[2:],
+	"terminated":          nil,
+}
+
+// canReach reports whether an instance in state from may still reach state to
+func canReach(from string, to string) bool {
+	later, ok := laterStates[from]
+	if !ok {
+		return true
+	}
+	for _, s := range later {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitOptions tunes the polling of the WaitFor functions, the zero value is usable
+type WaitOptions struct {
+	// Timeout is how long to wait in total, defaults to 15 minutes
+	Timeout time.Duration
+	// InitialInterval is the first delay between polls, defaults to 5 seconds. It doubles after every poll
+	InitialInterval time.Duration
+	// MaxInterval caps the delay between polls, defaults to 1 minute
+	MaxInterval time.Duration
+}
+
+// WaitError is returned by the WaitFor functions when the wanted state was not reached
+// it carries the last state observed so callers can see how far things got
+type WaitError struct {
+	Err       error
+	Instance  ServerInstance
+	Instances ServerInstances
+	LastErr   error
+}
+
+// Error implements the error interface
+func (e *WaitError) Error() string {
+	msg := e.Err.Error()
+	if e.Instance.Name != "" || e.Instance.State != "" {
+		msg = fmt.Sprintf("%s, instance %s last seen %s", msg, e.Instance.Name, e.Instance.State)
+	}
+	if e.Instances != nil {
+		msg = fmt.Sprintf("%s, array last seen with %d operational of %d instances",
+			msg, countState(e.Instances, "operational"), len(e.Instances))
+	}
+	if e.LastErr != nil {
+		msg = fmt.Sprintf("%s, last error %s", msg, e.LastErr)
+	}
+	return msg
+}
+
+// Cause returns the underlying reason for the wait failing, compatible with errors.Cause
+func (e *WaitError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the underlying reason for the wait failing, compatible with errors.Is
+func (e *WaitError) Unwrap() error {
+	return e.Err
+}
+
+// WaitForInstanceState polls an instance until it reaches the wanted state
+// Waiting stops early with ErrTerminalState if the instance reaches a state the wanted one cannot follow, such as
+// stranded when waiting for operational. Waiting for terminated carries on through decommissioning.
+// On failure a *WaitError is returned along with the last observed instance
+func (c Client) WaitForInstanceState(ctx context.Context, cloudID string, instanceID string, state string, opts ...WaitOptions) (ServerInstance, error) {
+	var last ServerInstance
+	err := poll(ctx, opts, func(ctx context.Context) (bool, error) {
+		instance, err := c.WithContext(ctx).Uncached().Instance(cloudID, instanceID)
+		if err != nil {
+			return false, err
+		}
+		last = instance
+		if instance.State == state {
+			return true, nil
+		}
+		if !canReach(instance.State, state) {
+			return true, &WaitError{Err: ErrTerminalState, Instance: instance}
+		}
+		return false, nil
+	}, func(cause error, lastErr error) error {
+		return &WaitError{Err: cause, Instance: last, LastErr: lastErr}
+	})
+	return last, err
+}
+
+// WaitForArrayCount polls an array until it has at least count operational instances
+// Waiting stops early with ErrTerminalState if any instance in the array is stranded, as a launch has failed.
+// On failure a *WaitError is returned along with the last observed instance list
+func (c Client) WaitForArrayCount(ctx context.Context, arrayID string, count int, opts ...WaitOptions) (ServerInstances, error) {
+	var last ServerInstances
+	err := poll(ctx, opts, func(ctx context.Context) (bool, error) {
+		instances, err := c.WithContext(ctx).Uncached().GetArrayInstances(arrayID)
+		if err != nil {
+			return false, err
+		}
+		last = instances
+		for _, i := range instances {
+			if i.State == "stranded" || i.State == "stranded in booting" {
+				return true, &WaitError{Err: ErrTerminalState, Instance: i, Instances: instances}
+			}
+		}
+		return countState(instances, "operational") >= count, nil
+	}, func(cause error, lastErr error) error {
+		return &WaitError{Err: cause, Instances: last, LastErr: lastErr}
+	})
+	return last, err
+}
+
+// poll calls check with exponential backoff until it reports done, returns an error of its own or time runs out
+// check is given the wait's context so its requests stop at the deadline too.
+// errors from check which are not a *WaitError are treated as transient and polling continues.
+// fail builds the error returned when the wait times out or the context is cancelled
+func poll(ctx context.Context, opts []WaitOptions, check func(ctx context.Context) (bool, error), fail func(cause error, lastErr error) error) error {
+	var o WaitOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 15 * time.Minute
+	}
+	if o.InitialInterval == 0 {
+		o.InitialInterval = 5 * time.Second
+	}
+	if o.MaxInterval == 0 {
+		o.MaxInterval = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+	interval := o.InitialInterval
+	var lastErr error
+	for {
+		done, err := check(ctx)
+		if waitErr, ok := err.(*WaitError); ok {
+			return waitErr
+		}
+		lastErr = err
+		if done && err == nil {
+			return nil
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if ctx.Err() == context.DeadlineExceeded {
+				return fail(ErrWaitTimeout, lastErr)
+			}
+			return fail(ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+		interval *= 2
+		if interval > o.MaxInterval {
+			interval = o.MaxInterval
+		}
+	}
+}
+
+// countState returns the number of instances in the given state
+func countState(instances ServerInstances, state string) int {
+	var n int
+	for _, i := range instances {
+		if i.State == state {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,67 @@
+package rightscale
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestWaitForInstanceState(t *testing.T) {
+	tests := []struct {
+		name   string
+		wanted string
+		states []string
+		want   error
+	}{
+		{name: "reached", wanted: "operational", states: []string{"booting", "operational"}},
+		{name: "terminal", wanted: "operational", states: []string{"stranded"}, want: ErrTerminalState},
+		{name: "going away", wanted: "operational", states: []string{"decommissioning"}, want: ErrTerminalState},
+		{name: "never reached", wanted: "operational", states: []string{"booting"}, want: ErrWaitTimeout},
+		{name: "terminated through decommissioning", wanted: "terminated",
+			states: []string{"operational", "decommissioning", "terminating", "terminated"}},
+		{name: "stranded then terminated", wanted: "terminated", states: []string{"stranded", "terminated"}},
+		{name: "terminated is final", wanted: "decommissioning", states: []string{"terminated"}, want: ErrTerminalState},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, c := newFakeAPI(t)
+			var bodies []string
+			for _, state := range tt.states {
+				bodies = append(bodies, `{"name":"i1","state":"`+state+`"}`)
+			}
+			api.onSequence("GET", "/api/clouds/1/instances/ABC", 200, bodies...)
+			opts := WaitOptions{Timeout: 500 * time.Millisecond, InitialInterval: 5 * time.Millisecond}
+			instance, err := c.WaitForInstanceState(context.Background(), "1", "ABC", tt.wanted, opts)
+			if errors.Cause(err) != tt.want {
+				t.Fatalf("WaitForInstanceState() error = %v, want %v", err, tt.want)
+			}
+			if last := tt.states[len(tt.states)-1]; instance.State != last {
+				t.Errorf("last state = %q, want %q", instance.State, last)
+			}
+		})
+	}
+}
+
+func TestWaitCancelsHungRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+	c := Client{EndPoint: server.URL}
+
+	start := time.Now()
+	_, err := c.WaitForArrayCount(context.Background(), "10", 1, WaitOptions{Timeout: 100 * time.Millisecond})
+	if errors.Cause(err) != ErrWaitTimeout {
+		t.Fatalf("WaitForArrayCount() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("WaitForArrayCount() returned after %s, the hung request outlived the deadline", elapsed)
+	}
+}
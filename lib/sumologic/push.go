@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -22,11 +22,53 @@ type UploaderWithCustomHeaders interface {
 	SendWithHeaders([]byte, string, HeaderSet) error
 }
 
+// SendInfo describes an upload the uploader is about to make
+type SendInfo struct {
+	URL        string
+	Name       string
+	Bytes      int
+	Compressed bool
+	Start      time.Time
+}
+
+// SendResult describes the outcome of an upload, StatusCode is 0 when Err is a network error
+type SendResult struct {
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// Hooks are called around every upload an uploader makes
+// implementations must be safe for concurrent use when the uploader is shared between goroutines
+type Hooks interface {
+	BeforeSend(info SendInfo)
+	AfterSend(info SendInfo, result SendResult)
+}
+
+// Option configures an uploader built by NewUploader or NewUploaderWithHeaders
+type Option func(*httpUploader)
+
+// WithLogger sends the uploader's log messages to logger, uploaders are silent by default
+func WithLogger(logger *slog.Logger) Option {
+	return func(u *httpUploader) {
+		u.logger = logger
+	}
+}
+
+// WithHooks calls hooks around every upload the uploader makes
+func WithHooks(hooks Hooks) Option {
+	return func(u *httpUploader) {
+		u.hooks = hooks
+	}
+}
+
 // httpUploader is a reusable object to upload data to a single
 // Sumologic HTTP collector.
 type httpUploader struct {
 	url       string
 	multiline bool
+	logger    *slog.Logger
+	hooks     Hooks
 	HeaderSet
 }
 
@@ -39,16 +81,25 @@ func (u *httpUploader) setHeaders(h HeaderSet) {
 }
 
 // NewUploader creates a new uploader.
-func NewUploader(url string) Uploader {
-	u := new(httpUploader)
-	u.url = url
-	return u
+func NewUploader(url string, opts ...Option) Uploader {
+	return newHTTPUploader(url, opts)
 }
 
 // NewUploader creates a new uploader.
-func NewUploaderWithHeaders(url string) UploaderWithCustomHeaders {
+func NewUploaderWithHeaders(url string, opts ...Option) UploaderWithCustomHeaders {
+	return newHTTPUploader(url, opts)
+}
+
+// newHTTPUploader builds an uploader and applies its options
+func newHTTPUploader(url string, opts []Option) *httpUploader {
 	u := new(httpUploader)
 	u.url = url
+	for _, opt := range opts {
+		opt(u)
+	}
+	if u.logger == nil {
+		u.logger = slog.New(slog.DiscardHandler)
+	}
 	return u
 }
 
@@ -143,7 +194,7 @@ func (u *httpUploader) Send(input []byte, name string) (err error) {
 	client.Timeout = 60 * time.Second
 
 	if len(input) > GzipThreshold {
-		log.Printf("Data over threshold, compressing (%v bytes)", len(input))
+		u.logger.Debug("data over threshold, compressing", "bytes", len(input))
 		w := gzip.NewWriter(buf)
 		n, err := w.Write(input)
 		if err != nil {
@@ -182,10 +233,22 @@ func (u *httpUploader) Send(input []byte, name string) (err error) {
 		req.Header.Set(key, value)
 	}
 
+	info := SendInfo{URL: u.url, Name: name, Bytes: len(input), Compressed: len(input) > GzipThreshold, Start: time.Now()}
+	if u.hooks != nil {
+		u.hooks.BeforeSend(info)
+	}
 	resp, err := client.Do(req)
+	if u.hooks != nil {
+		result := SendResult{Duration: time.Since(info.Start), Err: err}
+		if resp != nil {
+			result.StatusCode = resp.StatusCode
+		}
+		u.hooks.AfterSend(info, result)
+	}
 	if err != nil {
 		return
 	}
+	defer resp.Body.Close()
 
 	//log.Printf("Response: %s", resp.Status)
 
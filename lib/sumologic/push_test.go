@@ -0,0 +1,151 @@
+package sumologic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// received is an upload seen by a fake collector
+type received struct {
+	body     string
+	encoding string
+	headers  http.Header
+}
+
+// newCollector starts a fake Sumologic collector which answers every upload with status
+func newCollector(t *testing.T, status int) (*httptest.Server, *[]received) {
+	t.Helper()
+	var mu sync.Mutex
+	var uploads []received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, _ := io.ReadAll(body)
+		mu.Lock()
+		uploads = append(uploads, received{body: string(data), encoding: r.Header.Get("Content-Encoding"), headers: r.Header})
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &uploads
+}
+
+// recordingHooks keeps every call made to it
+type recordingHooks struct {
+	mu     sync.Mutex
+	before []SendInfo
+	after  []SendResult
+}
+
+func (h *recordingHooks) BeforeSend(info SendInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.before = append(h.before, info)
+}
+
+func (h *recordingHooks) AfterSend(info SendInfo, result SendResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.after = append(h.after, result)
+}
+
+func TestSend(t *testing.T) {
+	server, uploads := newCollector(t, http.StatusOK)
+	hooks := &recordingHooks{}
+	u := NewUploader(server.URL, WithHooks(hooks))
+
+	if err := u.Send([]byte("hello"), "app"); err != nil {
+		t.Fatalf("Send() error = %s", err)
+	}
+	if len(*uploads) != 1 || (*uploads)[0].body != "hello" || (*uploads)[0].encoding != "" {
+		t.Fatalf("uploads = %+v, want one uncompressed hello", *uploads)
+	}
+	if len(hooks.before) != 1 || len(hooks.after) != 1 {
+		t.Fatalf("hooks called %d and %d times, want once each", len(hooks.before), len(hooks.after))
+	}
+	info := hooks.before[0]
+	if info.URL != server.URL || info.Name != "app" || info.Bytes != 5 || info.Compressed || info.Start.IsZero() {
+		t.Errorf("SendInfo = %+v", info)
+	}
+	if result := hooks.after[0]; result.StatusCode != http.StatusOK || result.Err != nil || result.Duration <= 0 {
+		t.Errorf("SendResult = %+v, want a 200 without an error", result)
+	}
+
+	if err := u.Send(nil, "app"); err != nil || len(*uploads) != 1 || len(hooks.before) != 1 {
+		t.Errorf("Send(nil) = %v, want nothing sent", err)
+	}
+}
+
+func TestSendCompressesLargeMessages(t *testing.T) {
+	defer func(threshold int) { GzipThreshold = threshold }(GzipThreshold)
+	GzipThreshold = 10
+
+	server, uploads := newCollector(t, http.StatusOK)
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	hooks := &recordingHooks{}
+	u := NewUploader(server.URL, WithLogger(logger), WithHooks(hooks))
+
+	message := strings.Repeat("x", 20)
+	if err := u.Send([]byte(message), ""); err != nil {
+		t.Fatalf("Send() error = %s", err)
+	}
+	if len(*uploads) != 1 || (*uploads)[0].body != message || (*uploads)[0].encoding != "gzip" {
+		t.Errorf("uploads = %+v, want one gzipped message", *uploads)
+	}
+	if !hooks.before[0].Compressed {
+		t.Error("SendInfo.Compressed = false for a compressed upload")
+	}
+	if !strings.Contains(logs.String(), "data over threshold, compressing") || !strings.Contains(logs.String(), "bytes=20") {
+		t.Errorf("logs = %q, want the compression logged", logs.String())
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	server, _ := newCollector(t, http.StatusServiceUnavailable)
+	hooks := &recordingHooks{}
+	err := NewUploader(server.URL, WithHooks(hooks)).Send([]byte("hello"), "")
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Send() error = %v, want the 503 status", err)
+	}
+	if result := hooks.after[0]; result.StatusCode != http.StatusServiceUnavailable || result.Err != nil {
+		t.Errorf("SendResult = %+v, want the 503 without a network error", result)
+	}
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	hooks = &recordingHooks{}
+	if err := NewUploader(closed.URL, WithHooks(hooks)).Send([]byte("hello"), ""); err == nil {
+		t.Error("Send() to a closed collector did not fail")
+	}
+	if result := hooks.after[0]; result.StatusCode != 0 || result.Err == nil {
+		t.Errorf("SendResult = %+v, want status 0 and the network error", result)
+	}
+}
+
+func TestSendWithHeaders(t *testing.T) {
+	server, uploads := newCollector(t, http.StatusOK)
+	//a nil logger is the same as no logger
+	u := NewUploaderWithHeaders(server.URL, WithLogger(nil))
+	headers := HeaderSet{Headers: map[string]string{"X-Sumo-Category": "prod/app"}}
+	if err := u.SendWithHeaders([]byte("hello"), "", headers); err != nil {
+		t.Fatalf("SendWithHeaders() error = %s", err)
+	}
+	if got := (*uploads)[0].headers.Get("X-Sumo-Category"); got != "prod/app" {
+		t.Errorf("X-Sumo-Category = %q, want prod/app", got)
+	}
+}